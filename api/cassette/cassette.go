@@ -0,0 +1,109 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Interaction represents a single recorded request and the response that was returned
+type Interaction struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// Cassette replays recorded HTTP interactions and implements http.RoundTripper.
+// If the same request was recorded multiple times, the responses are returned
+// in the order they were recorded. The last one is repeated afterwards
+type Cassette struct {
+	interactions map[string][]Interaction
+	played       map[string]int
+	mutex        sync.Mutex
+}
+
+// Load reads a cassette from a JSON file containing a list of interactions
+func Load(filename string) (*Cassette, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("unmarshaling cassette: %w", err)
+	}
+
+	cassette := &Cassette{
+		interactions: make(map[string][]Interaction),
+		played:       make(map[string]int),
+	}
+	for _, interaction := range interactions {
+		key := requestKey(interaction.Method, interaction.URL)
+		cassette.interactions[key] = append(cassette.interactions[key], interaction)
+	}
+	return cassette, nil
+}
+
+// requestKey returns the key that is used to look up a recorded interaction
+func requestKey(method, url string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return strings.ToUpper(method) + " " + url
+}
+
+// RoundTrip returns the recorded response for the request
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Behave like a real transport for cancelled requests, e.g. after a deadline
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	key := requestKey(req.Method, req.URL.String())
+
+	c.mutex.Lock()
+	recorded := c.interactions[key]
+	if len(recorded) == 0 {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("no recorded interaction for %s", key)
+	}
+	index := c.played[key]
+	if index < len(recorded)-1 {
+		c.played[key]++
+	}
+	interaction := recorded[index]
+	c.mutex.Unlock()
+
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	status := interaction.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	for name, value := range interaction.Headers {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(string(interaction.Body))),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}, nil
+}
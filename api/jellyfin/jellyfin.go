@@ -1,12 +1,19 @@
 package jellyfin
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/forceu/jellyfinmanager/models"
@@ -16,21 +23,187 @@ import (
 type Client struct {
 	config     models.Config
 	httpClient *http.Client
+	deviceID   string
+	userAgent  string
+	ctx        context.Context
+	// busyNotice is called before a request is repeated because the server was busy
+	busyNotice func(err error, wait time.Duration)
+	// retries is the number of times a failed request is repeated, see WithRetries
+	retries int
+	// primaryImageTags requests the tag of the primary image of movies and episodes
+	primaryImageTags bool
+	// writeDenied is set once the server rejected a change, see WriteDenied
+	writeDenied bool
+	// pageWorkers is the number of pages of the library that are fetched at the same time
+	pageWorkers int
+}
+
+// defaultDeviceID is sent as DeviceId in the authorization header if WithDeviceID is not used
+const defaultDeviceID = "jellyfinmanager"
+
+// Option configures optional settings of a Client
+type Option func(*Client)
+
+// WithTransport sets the RoundTripper that is used for all requests,
+// e.g. to replay recorded interactions instead of contacting the server
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = rt
+	}
+}
+
+// WithDeviceID sets the DeviceId that is sent to the server, so the requests of a run
+// can be found in the sessions and logs of the server
+func WithDeviceID(deviceID string) Option {
+	return func(c *Client) {
+		c.deviceID = deviceID
+	}
+}
+
+// WithContext sets the context of all requests, e.g. to stop them once a deadline has passed
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+// WithUserAgent sets the User-Agent header of all requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithBusyNotice sets a function that is called before a request is repeated because
+// the server was busy or not reachable, e.g. to tell the user that a library scan is slowing down the run
+func WithBusyNotice(notice func(err error, wait time.Duration)) Option {
+	return func(c *Client) {
+		c.busyNotice = notice
+	}
+}
+
+// WithRetries sets how often a request is repeated after a network error or a temporary
+// error status, see makeRequest. Failed requests are not repeated if n is 0
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.retries = max(n, 0)
+	}
+}
+
+// WithPrimaryImageTags stores the tag of the primary image of movies and episodes
+// in the PrimaryImageTag of the watched items
+func WithPrimaryImageTags() Option {
+	return func(c *Client) {
+		c.primaryImageTags = true
+	}
+}
+
+// WithPageWorkers fetches up to n pages of the library at the same time in GetUserItems,
+// which speeds up large libraries. Pages are fetched one after another if n is 1 or less
+func WithPageWorkers(n int) Option {
+	return func(c *Client) {
+		c.pageWorkers = n
+	}
+}
+
+// NormalizeServerURL adds http:// to a server URL without a scheme and removes trailing
+// slashes, e.g. localhost:8096/ becomes http://localhost:8096
+func NormalizeServerURL(serverURL string) (string, error) {
+	input := strings.TrimSpace(serverURL)
+	serverURL = input
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "http://" + serverURL
+	}
+	serverURL = strings.TrimRight(serverURL, "/")
+
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %w", input, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("invalid server URL %q: scheme must be http or https", input)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid server URL %q: host is missing", input)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("invalid server URL %q: must not contain a query or fragment", input)
+	}
+	return serverURL, nil
 }
 
 // NewClient creates a new Jellyfin API client
-func NewClient(config models.Config) (*Client, error) {
+func NewClient(config models.Config, options ...Option) (*Client, error) {
+	serverURL, err := NormalizeServerURL(config.ServerURL)
+	if err != nil {
+		return nil, err
+	}
+	config.ServerURL = serverURL
 	client := &Client{
 		config: config,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		deviceID: defaultDeviceID,
+		ctx:      context.Background(),
+		retries:  DefaultRetries,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	// An unknown version is not fatal, the endpoints of current versions are used in that case
+	_ = client.detectServerVersion()
+	if config.UserID != "" {
+		return client, client.ValidateUserId()
+	}
+	// Without a user, only requests that do not depend on one are possible, e.g. GetUsers
+	if config.UserName == "" {
+		return client, nil
 	}
 	return client, client.ParseUserId()
 }
 
-func (c *Client) ParseUserId() error {
-	endpoint := "/Users"
+// detectServerVersion reads the version of the server, which does not require authentication
+func (c *Client) detectServerVersion() error {
+	resp, err := c.makeRequest("GET", "/System/Info/Public", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Version string `json:"Version"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("decoding server info: %w", err)
+	}
+	c.config.ServerVersion = result.Version
+	return nil
+}
+
+// isServerOlderThan returns true if the server version is known and lower than the given
+// major, minor version. If the version could not be detected, the server is assumed to be current
+func (c *Client) isServerOlderThan(major, minor int) bool {
+	parts := strings.Split(c.config.ServerVersion, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	serverMajor, errMajor := strconv.Atoi(parts[0])
+	serverMinor, errMinor := strconv.Atoi(parts[1])
+	if errMajor != nil || errMinor != nil {
+		return false
+	}
+	if serverMajor != major {
+		return serverMajor < major
+	}
+	return serverMinor < minor
+}
+
+// ValidateUserId checks that the configured user ID exists and sets the user name.
+// Unlike ParseUserId, this does not require permission to list all users
+func (c *Client) ValidateUserId() error {
+	endpoint := "/Users/" + url.PathEscape(c.config.UserID)
 
 	resp, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
@@ -38,7 +211,7 @@ func (c *Client) ParseUserId() error {
 	}
 	defer resp.Body.Close()
 
-	var result []struct {
+	var result struct {
 		Name string `json:"Name"`
 		ID   string `json:"Id"`
 	}
@@ -47,8 +220,28 @@ func (c *Client) ParseUserId() error {
 	if err != nil {
 		return fmt.Errorf("decoding response: %w", err)
 	}
-	for _, user := range result {
+	if c.config.UserName != "" && !strings.EqualFold(c.config.UserName, result.Name) {
+		return fmt.Errorf("user ID %s belongs to %s, not %s", c.config.UserID, result.Name, c.config.UserName)
+	}
+	c.config.UserName = result.Name
+	return nil
+}
+
+// ParseUserId looks up the ID of the configured user name
+func (c *Client) ParseUserId() error {
+	// An empty name would match a user without name and is never intended
+	if strings.TrimSpace(c.config.UserName) == "" {
+		return errors.New("no user name given")
+	}
+	users, err := c.GetUsers()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
 		if strings.ToLower(user.Name) == strings.ToLower(c.config.UserName) {
+			if user.ID == "" {
+				return fmt.Errorf("%w: the server returned user %s without ID", ErrNoUserID, user.Name)
+			}
 			c.config.UserID = user.ID
 			return nil
 		}
@@ -56,21 +249,185 @@ func (c *Client) ParseUserId() error {
 	return fmt.Errorf("user not found: %s", c.config.UserName)
 }
 
+// ErrNoUserID is returned if an operation requires a user, but no user ID was resolved
+var ErrNoUserID = errors.New("no user ID resolved")
+
+// RequireUserID returns ErrNoUserID if the client has no user ID. Requests for the
+// user's items would otherwise be sent with an empty userId and fail with unclear errors
+func (c *Client) RequireUserID() error {
+	if strings.TrimSpace(c.config.UserID) == "" {
+		return ErrNoUserID
+	}
+	return nil
+}
+
+// WithUser returns a copy of the client for another user of the same server
+func (c *Client) WithUser(userName string) (*Client, error) {
+	userClient := *c
+	userClient.config.UserName = userName
+	userClient.config.UserID = ""
+	if err := userClient.ParseUserId(); err != nil {
+		return nil, err
+	}
+	return &userClient, nil
+}
+
+// User is an account on the Jellyfin server
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetUsers returns all users of the server
+func (c *Client) GetUsers() ([]User, error) {
+	resp, err := c.makeRequest("GET", "/Users", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result []struct {
+		Name string `json:"Name"`
+		ID   string `json:"Id"`
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	users := make([]User, len(result))
+	for i, user := range result {
+		users[i] = User{ID: user.ID, Name: user.Name}
+	}
+	return users, nil
+}
+
+// Context returns the context of all requests of the client
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
 // GetConfig returns the client configuration
 func (c *Client) GetConfig() models.Config {
 	return c.config
 }
 
-// makeRequest performs an authenticated request to Jellyfin API
+// WriteDenied returns true if the server has rejected a change because the API key
+// may not write, so no further changes need to be attempted
+func (c *Client) WriteDenied() bool {
+	return c.writeDenied
+}
+
+// ErrServerBusy is returned if the server still reported a temporary error after all retries,
+// which usually happens while a library scan is running
+var ErrServerBusy = errors.New("server is busy, a library scan may be running")
+
+// ErrWriteDenied is returned if the server rejected a change with 403 Forbidden,
+// which happens with read-only API keys
+var ErrWriteDenied = errors.New("API key lacks write permission")
+
+// ErrTemporary is returned if the server or a reverse proxy in front of it still responded
+// with a temporary error status after all retries
+var ErrTemporary = errors.New("server returned a temporary error")
+
+// DefaultRetries is the number of times a failed request is repeated unless WithRetries is used
+const DefaultRetries = 3
+
+// retryBackoff is the wait before a request is repeated the first time, it doubles for every further retry
+const retryBackoff = 2 * time.Second
+
+// isTemporaryStatus returns true for statuses that usually go away when the request is
+// repeated, e.g. while a reverse proxy cannot reach Jellyfin or requests are rate limited
+func isTemporaryStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryable returns true if a request failed because the server was busy, returned a
+// temporary error status or the connection failed, e.g. because it was reset
+func isRetryable(err error) bool {
+	if errors.Is(err, ErrServerBusy) || errors.Is(err, ErrTemporary) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// busyMessages are parts of error responses that Jellyfin returns while items are being
+// refreshed or the database is locked by a library scan
+var busyMessages = []string{"being refreshed", "database is locked", "library scan"}
+
+// isServerBusy returns true if the response is a temporary error that goes away once
+// the server has finished its current work
+func isServerBusy(statusCode int, body string) bool {
+	if statusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	if statusCode < http.StatusInternalServerError {
+		return false
+	}
+	body = strings.ToLower(body)
+	for _, message := range busyMessages {
+		if strings.Contains(body, message) {
+			return true
+		}
+	}
+	return false
+}
+
+// makeRequest performs an authenticated request to Jellyfin API. Requests are repeated
+// with increasing, randomised waits if the server is busy, e.g. during a library scan,
+// after temporary error statuses and after network errors. Other errors fail immediately
 func (c *Client) makeRequest(method, endpoint string, body io.Reader) (*http.Response, error) {
+	// The body is read once, so it can be sent again on retries
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doRequest(method, endpoint, payload)
+		if err == nil || attempt == c.retries || c.ctx.Err() != nil || !isRetryable(err) {
+			return resp, err
+		}
+		// The jitter keeps concurrent page requests from being repeated at the same time
+		wait := (backoff + rand.N(backoff/2)).Round(100 * time.Millisecond)
+		if c.busyNotice != nil {
+			c.busyNotice(err, wait)
+		}
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			return nil, fmt.Errorf("executing request: %w", c.ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// doRequest performs a single authenticated request to Jellyfin API
+func (c *Client) doRequest(method, endpoint string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
 	reqURL := c.config.ServerURL + endpoint
-	req, err := http.NewRequest(method, reqURL, body)
+	req, err := http.NewRequestWithContext(c.ctx, method, reqURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	// Use the official Authorization header format preferred by Jellyfin
-	authHeader := fmt.Sprintf("MediaBrowser Client=\"Jellyfin Manager\", Device=\"Go Client\", DeviceId=\"TraceID\", Version=\"1.0.0\", Token=\"%s\"", c.config.APIKey)
+	authHeader := fmt.Sprintf("MediaBrowser Client=\"Jellyfin Manager\", Device=\"Go Client\", DeviceId=\"%s\", Version=\"1.0.0\", Token=\"%s\"", c.deviceID, c.config.APIKey)
 	req.Header.Set("Authorization", authHeader)
 
 	// Fallback/Legacy header (optional, but good for compatibility)
@@ -78,6 +435,9 @@ func (c *Client) makeRequest(method, endpoint string, body io.Reader) (*http.Res
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("executing request: %w", err)
@@ -85,44 +445,224 @@ func (c *Client) makeRequest(method, endpoint string, body io.Reader) (*http.Res
 
 	if resp.StatusCode != http.StatusOK {
 		output, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if isServerBusy(resp.StatusCode, string(output)) {
+			return nil, fmt.Errorf("%w (status %d: %s)", ErrServerBusy, resp.StatusCode, string(output))
+		}
+		if isTemporaryStatus(resp.StatusCode) {
+			return nil, fmt.Errorf("%w (status %d: %s)", ErrTemporary, resp.StatusCode, string(output))
+		}
+		if resp.StatusCode == http.StatusForbidden && method != http.MethodGet {
+			c.writeDenied = true
+			return nil, fmt.Errorf("%w (status %d: %s)", ErrWriteDenied, resp.StatusCode, string(output))
+		}
 		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(output))
 	}
 
 	return resp, nil
 }
 
+// itemsPageSize is the number of items requested per page when fetching the library
+const itemsPageSize = 1000
+
+// UserItem represents a movie or episode together with the user's data for it
+type UserItem struct {
+	Item                  models.WatchedItem
+	Played                bool
+	IsFavorite            bool
+	PlayCount             int
+	PlaybackPositionTicks int64
+	// PlayedPercentage is how much of an item in progress was played, from 0 to 100
+	PlayedPercentage float64
+	RuntimeTicks     int64
+	Rating           *float64
+}
+
+// IsWatched returns true if the item has been played or if the playback position is at
+// least the given fraction of the runtime. With a threshold of 0, only played items count
+func (u UserItem) IsWatched(threshold float64) bool {
+	if u.Played {
+		return true
+	}
+	if threshold <= 0 || u.RuntimeTicks <= 0 {
+		return false
+	}
+	return float64(u.PlaybackPositionTicks)/float64(u.RuntimeTicks) >= threshold
+}
+
+// InProgress returns true if the item was started but not finished, so it has a resume position
+func (u UserItem) InProgress() bool {
+	return !u.Played && u.PlaybackPositionTicks > 0
+}
+
 // GetWatchedItems retrieves all watched items from Jellyfin
 func (c *Client) GetWatchedItems() ([]models.WatchedItem, error) {
-	endpoint := fmt.Sprintf("/Items?userId=%s&Filters=IsPlayed&Recursive=true&IncludeItemTypes=Movie,Episode&Fields=Path,ProviderIds,SeriesName,SeasonName",
-		c.config.UserID)
+	userItems, err := c.GetUserItems()
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := c.makeRequest("GET", endpoint, nil)
+	watchedItems := make([]models.WatchedItem, 0, len(userItems))
+	for _, userItem := range userItems {
+		if userItem.IsWatched(0) {
+			watchedItems = append(watchedItems, userItem.Item)
+		}
+	}
+	return watchedItems, nil
+}
+
+// GetFavoriteItems retrieves all movies and episodes that the user marked as favorites,
+// whether they were watched or not
+func (c *Client) GetFavoriteItems() ([]models.WatchedItem, error) {
+	userItems, err := c.GetUserItems()
 	if err != nil {
 		return nil, err
 	}
+
+	var favorites []models.WatchedItem
+	for _, userItem := range userItems {
+		if userItem.IsFavorite {
+			item := userItem.Item
+			item.IsFavorite = true
+			favorites = append(favorites, item)
+		}
+	}
+	return favorites, nil
+}
+
+// GetUserItems retrieves all movies and episodes with the user's data in a single pass,
+// so that watched state, favorites and ratings can be categorised without additional requests
+func (c *Client) GetUserItems() ([]UserItem, error) {
+	if c.pageWorkers > 1 {
+		return c.getUserItemsConcurrently()
+	}
+	return c.getUserItemsSequentially(nil)
+}
+
+// getUserItemsSequentially fetches the pages after the given items one after another. Each page
+// starts after the items received so far, as servers may send fewer items than requested
+func (c *Client) getUserItemsSequentially(userItems []UserItem) ([]UserItem, error) {
+	total := 0
+	for {
+		startIndex := len(userItems)
+		page, pageTotal, err := c.getUserItemsPage(startIndex, itemsPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("fetching items from %d: %w", startIndex, err)
+		}
+		total = pageTotal
+		userItems = append(userItems, page...)
+		if len(page) == 0 || len(userItems) >= total {
+			break
+		}
+	}
+	return userItems, checkItemCount(len(userItems), total)
+}
+
+// ErrIncompleteItems is returned if the server sent fewer items than it reported in total
+var ErrIncompleteItems = errors.New("incomplete list of items")
+
+// checkItemCount returns ErrIncompleteItems if fewer items than the total were received, so
+// a short list is never used as if it were the whole library, e.g. for a backup
+func checkItemCount(received, total int) error {
+	if received < total {
+		return fmt.Errorf("%w: received %d of %d items", ErrIncompleteItems, received, total)
+	}
+	return nil
+}
+
+// getUserItemsConcurrently fetches the first page to learn the number of items and then the
+// remaining pages with up to pageWorkers requests at the same time. The pages are assembled in order
+func (c *Client) getUserItemsConcurrently() ([]UserItem, error) {
+	userItems, total, err := c.getUserItemsPage(0, itemsPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("fetching items from 0: %w", err)
+	}
+	if len(userItems) == 0 || len(userItems) >= total {
+		return userItems, checkItemCount(len(userItems), total)
+	}
+	// The server limits the size of the pages, so their offsets are only known one after another
+	if len(userItems) < itemsPageSize {
+		return c.getUserItemsSequentially(userItems)
+	}
+
+	remaining := (total - 1) / itemsPageSize
+	pages := make([][]UserItem, remaining)
+	errs := make([]error, remaining)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(c.pageWorkers, remaining) {
+		wg.Go(func() {
+			for i := range indexes {
+				pages[i], _, errs[i] = c.getUserItemsPage((i+1)*itemsPageSize, itemsPageSize)
+			}
+		})
+	}
+	for i := range remaining {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for i, page := range pages {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("fetching items from %d: %w", (i+1)*itemsPageSize, errs[i])
+		}
+		userItems = append(userItems, page...)
+	}
+	return userItems, checkItemCount(len(userItems), total)
+}
+
+// getUserItemsPage retrieves a single page of movies and episodes and returns the total number of items
+func (c *Client) getUserItemsPage(startIndex, limit int) ([]UserItem, int, error) {
+	endpoint := fmt.Sprintf("/Items?userId=%s&Recursive=true&IncludeItemTypes=Movie,Episode&Fields=Path,ProviderIds,SeriesName,SeasonName,UserData,DateCreated&EnableUserData=true&SortBy=SortName&StartIndex=%d&Limit=%d",
+		c.config.UserID, startIndex, limit)
+	if c.primaryImageTags {
+		endpoint += "&EnableImageTypes=Primary&ImageTypeLimit=1"
+	}
+
+	resp, err := c.makeRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer resp.Body.Close()
 
 	var result struct {
 		Items []struct {
-			ID          string            `json:"Id"`
-			Name        string            `json:"Name"`
-			Type        string            `json:"Type"`
-			Path        string            `json:"Path"`
-			ProviderIds map[string]string `json:"ProviderIds"`
-			SeriesName  string            `json:"SeriesName"`
-			SeasonName  string            `json:"SeasonName"`
-			UserData    struct {
-				PlayedDate time.Time `json:"LastPlayedDate"`
+			ID            string            `json:"Id"`
+			Name          string            `json:"Name"`
+			Type          string            `json:"Type"`
+			Path          string            `json:"Path"`
+			ProviderIds   map[string]string `json:"ProviderIds"`
+			SeriesName    string            `json:"SeriesName"`
+			SeasonName    string            `json:"SeasonName"`
+			SeasonNumber  *int              `json:"ParentIndexNumber"`
+			EpisodeNumber *int              `json:"IndexNumber"`
+			RuntimeTicks  int64             `json:"RunTimeTicks"`
+			// ProductionYear is always returned and does not need to be requested in Fields
+			ProductionYear int       `json:"ProductionYear"`
+			DateCreated    time.Time `json:"DateCreated"`
+			ImageTags      struct {
+				Primary string `json:"Primary"`
+			} `json:"ImageTags"`
+			UserData struct {
+				PlayedDate            time.Time `json:"LastPlayedDate"`
+				Played                bool      `json:"Played"`
+				IsFavorite            bool      `json:"IsFavorite"`
+				PlayCount             int       `json:"PlayCount"`
+				PlaybackPositionTicks int64     `json:"PlaybackPositionTicks"`
+				PlayedPercentage      float64   `json:"PlayedPercentage"`
+				Rating                *float64  `json:"Rating"`
 			} `json:"UserData"`
 		} `json:"Items"`
+		TotalRecordCount int `json:"TotalRecordCount"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+		return nil, 0, fmt.Errorf("decoding response: %w", err)
 	}
 
-	watchedItems := make([]models.WatchedItem, 0, len(result.Items))
+	userItems := make([]UserItem, 0, len(result.Items))
 	for _, item := range result.Items {
 		var typeItem int
 		switch item.Type {
@@ -135,18 +675,39 @@ func (c *Client) GetWatchedItems() ([]models.WatchedItem, error) {
 		}
 
 		wi := models.WatchedItem{
-			ID:          item.ID,
-			Name:        item.Name,
-			Type:        typeItem,
-			PlayedDate:  item.UserData.PlayedDate,
-			ProviderIDs: item.ProviderIds,
-			SeriesName:  item.SeriesName,
-			SeasonName:  item.SeasonName,
+			ID:             item.ID,
+			Name:           item.Name,
+			Type:           typeItem,
+			PlayedDate:     item.UserData.PlayedDate,
+			ProviderIDs:    item.ProviderIds,
+			SeriesName:     item.SeriesName,
+			SeasonName:     item.SeasonName,
+			ProductionYear: item.ProductionYear,
+			Path:           item.Path,
+		}
+		if !item.DateCreated.IsZero() {
+			wi.DateAdded = &item.DateCreated
+		}
+		if c.primaryImageTags {
+			wi.PrimaryImageTag = item.ImageTags.Primary
 		}
-		watchedItems = append(watchedItems, wi)
+		if typeItem == models.TypeEpisode {
+			wi.SeasonNumber = item.SeasonNumber
+			wi.EpisodeNumber = item.EpisodeNumber
+		}
+		userItems = append(userItems, UserItem{
+			Item:                  wi,
+			Played:                item.UserData.Played,
+			IsFavorite:            item.UserData.IsFavorite,
+			PlayCount:             item.UserData.PlayCount,
+			PlaybackPositionTicks: item.UserData.PlaybackPositionTicks,
+			PlayedPercentage:      item.UserData.PlayedPercentage,
+			RuntimeTicks:          item.RuntimeTicks,
+			Rating:                item.UserData.Rating,
+		})
 	}
 
-	return watchedItems, nil
+	return userItems, result.TotalRecordCount, nil
 }
 
 // getSeasonName retrieves the season name for an episode
@@ -167,9 +728,68 @@ func (c *Client) getSeasonName(episodeID string) (string, error) {
 	return result.SeasonName, err
 }
 
+// playedItemsEndpoint returns the endpoint for changing the watched state of an item.
+// Servers before 10.9 only support the user-scoped endpoint, which has since been deprecated
+func (c *Client) playedItemsEndpoint(itemID string) string {
+	if c.isServerOlderThan(10, 9) {
+		return fmt.Sprintf("/Users/%s/PlayedItems/%s", c.config.UserID, itemID)
+	}
+	return fmt.Sprintf("/UserPlayedItems/%s?userId=%s", itemID, c.config.UserID)
+}
+
+// playedStatesBatchSize is the maximum number of item IDs per request of GetPlayedStates,
+// so the URL does not get too long
+const playedStatesBatchSize = 100
+
+// GetPlayedStates returns whether the user has played each of the items, requesting them
+// in batches instead of one by one. Items that do not exist are left out
+func (c *Client) GetPlayedStates(ids []string) (map[string]bool, error) {
+	states := make(map[string]bool, len(ids))
+	for start := 0; start < len(ids); start += playedStatesBatchSize {
+		end := min(start+playedStatesBatchSize, len(ids))
+		endpoint := fmt.Sprintf("/Items?userId=%s&Ids=%s&Fields=UserData&EnableUserData=true",
+			c.config.UserID, strings.Join(ids[start:end], ","))
+
+		resp, err := c.makeRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Items []struct {
+				ID       string `json:"Id"`
+				UserData struct {
+					Played bool `json:"Played"`
+				} `json:"UserData"`
+			} `json:"Items"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decoding played states: %w", err)
+		}
+		for _, item := range result.Items {
+			states[item.ID] = item.UserData.Played
+		}
+	}
+	return states, nil
+}
+
 // MarkAsWatched marks an item as watched
 func (c *Client) MarkAsWatched(itemID string) error {
-	endpoint := fmt.Sprintf("/UserPlayedItems/%s?userId=%s", itemID, c.config.UserID)
+	return c.MarkAsWatchedAt(itemID, time.Time{})
+}
+
+// MarkAsWatchedAt marks an item as watched and sets its last played date, which orders the
+// Next Up list. The server uses the current time if the date is zero
+func (c *Client) MarkAsWatchedAt(itemID string, playedAt time.Time) error {
+	endpoint := c.playedItemsEndpoint(itemID)
+	if !playedAt.IsZero() {
+		separator := "?"
+		if strings.Contains(endpoint, "?") {
+			separator = "&"
+		}
+		endpoint += separator + "datePlayed=" + url.QueryEscape(playedAt.UTC().Format(time.RFC3339))
+	}
 
 	resp, err := c.makeRequest("POST", endpoint, nil)
 	if err != nil {
@@ -180,47 +800,154 @@ func (c *Client) MarkAsWatched(itemID string) error {
 	return nil
 }
 
-// FindSeriesID finds the Jellyfin ID for a series by name
-func (c *Client) FindSeriesID(seriesName string) (string, error) {
-	endpoint := fmt.Sprintf("/Items?userId=%s&SearchTerm=%s&IncludeItemTypes=Series&Recursive=true&Limit=10",
+// MarkAsUnwatched removes the watched state of an item
+func (c *Client) MarkAsUnwatched(itemID string) error {
+	endpoint := c.playedItemsEndpoint(itemID)
+
+	resp, err := c.makeRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// SetFavorite marks an item as a favorite, or removes it from the favorites
+func (c *Client) SetFavorite(itemID string, favorite bool) error {
+	endpoint := fmt.Sprintf("/UserFavoriteItems/%s?userId=%s", itemID, c.config.UserID)
+	if c.isServerOlderThan(10, 9) {
+		endpoint = fmt.Sprintf("/Users/%s/FavoriteItems/%s", c.config.UserID, itemID)
+	}
+	method := "POST"
+	if !favorite {
+		method = "DELETE"
+	}
+
+	resp, err := c.makeRequest(method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// SetRating sets the user's rating of an item, from 0 to 10
+func (c *Client) SetRating(itemID string, rating float64) error {
+	body, err := json.Marshal(struct {
+		Rating float64 `json:"Rating"`
+	}{Rating: rating})
+	if err != nil {
+		return fmt.Errorf("encoding rating: %w", err)
+	}
+	return c.updateUserData(itemID, body)
+}
+
+// SetPlaybackPosition sets the resume position of an item and its last played date, so it
+// appears in the Continue Watching list. The date is left unchanged if it is zero
+func (c *Client) SetPlaybackPosition(itemID string, positionTicks int64, playedDate time.Time) error {
+	data := struct {
+		PlaybackPositionTicks int64      `json:"PlaybackPositionTicks"`
+		LastPlayedDate        *time.Time `json:"LastPlayedDate,omitempty"`
+	}{PlaybackPositionTicks: positionTicks}
+	if !playedDate.IsZero() {
+		data.LastPlayedDate = &playedDate
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding playback position: %w", err)
+	}
+	return c.updateUserData(itemID, body)
+}
+
+// updateUserData changes the given fields of the user's data for an item
+func (c *Client) updateUserData(itemID string, body []byte) error {
+	endpoint := fmt.Sprintf("/UserItems/%s/UserData?userId=%s", itemID, c.config.UserID)
+	if c.isServerOlderThan(10, 9) {
+		endpoint = fmt.Sprintf("/Users/%s/Items/%s/UserData", c.config.UserID, itemID)
+	}
+
+	resp, err := c.makeRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return nil
+}
+
+// FindSeriesID finds the Jellyfin ID for a series by name, see FindSeries
+func (c *Client) FindSeriesID(seriesName string, exactOnly bool) (string, error) {
+	series, err := c.FindSeries(seriesName, exactOnly)
+	if err != nil {
+		return "", err
+	}
+	return series.ID, nil
+}
+
+// FindSeries finds a series by name. If there is no exact match, the first search result is
+// returned, or an error if exactOnly is set
+func (c *Client) FindSeries(seriesName string, exactOnly bool) (SeriesInfo, error) {
+	endpoint := fmt.Sprintf("/Items?userId=%s&SearchTerm=%s&IncludeItemTypes=Series&Recursive=true&Fields=ProviderIds&EnableUserData=true&Limit=10",
 		c.config.UserID, url.QueryEscape(seriesName))
 
 	resp, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
-		return "", err
+		return SeriesInfo{}, err
 	}
 	defer resp.Body.Close()
 
 	var result struct {
-		Items []struct {
-			ID   string `json:"Id"`
-			Name string `json:"Name"`
-		} `json:"Items"`
+		Items []seriesItem `json:"Items"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return "", fmt.Errorf("decoding series search: %w", err)
+		return SeriesInfo{}, fmt.Errorf("decoding series search: %w", err)
 	}
 
 	// Find an exact match
 	for _, item := range result.Items {
 		if item.Name == seriesName {
-			return item.ID, nil
+			return item.toSeriesInfo(), nil
 		}
 	}
 
 	// If no exact match, return the first result if available
+	if len(result.Items) > 0 && exactOnly {
+		return SeriesInfo{}, fmt.Errorf("series not found: %s (closest match: %s)", seriesName, result.Items[0].Name)
+	}
 	if len(result.Items) > 0 {
-		return result.Items[0].ID, nil
+		return result.Items[0].toSeriesInfo(), nil
 	}
 
-	return "", fmt.Errorf("series not found: %s", seriesName)
+	return SeriesInfo{}, fmt.Errorf("series not found: %s", seriesName)
+}
+
+// seriesItem is a series as returned by the /Items endpoint
+type seriesItem struct {
+	ID          string            `json:"Id"`
+	Name        string            `json:"Name"`
+	ProviderIds map[string]string `json:"ProviderIds"`
+	UserData    struct {
+		UnplayedItemCount *int `json:"UnplayedItemCount"`
+	} `json:"UserData"`
+}
+
+// toSeriesInfo converts the API response to a SeriesInfo
+func (s seriesItem) toSeriesInfo() SeriesInfo {
+	return SeriesInfo{
+		ID:                s.ID,
+		Name:              s.Name,
+		ProviderIDs:       s.ProviderIds,
+		UnplayedItemCount: s.UserData.UnplayedItemCount,
+	}
 }
 
 // GetAllSeries retrieves all series from Jellyfin
 func (c *Client) GetAllSeries() ([]SeriesInfo, error) {
-	endpoint := fmt.Sprintf("/Items?userId=%s&Recursive=true&IncludeItemTypes=Series&Fields=ProviderIds", c.config.UserID)
+	endpoint := fmt.Sprintf("/Items?userId=%s&Recursive=true&IncludeItemTypes=Series&Fields=ProviderIds&EnableUserData=true", c.config.UserID)
 
 	resp, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
@@ -229,11 +956,7 @@ func (c *Client) GetAllSeries() ([]SeriesInfo, error) {
 	defer resp.Body.Close()
 
 	var result struct {
-		Items []struct {
-			ID          string            `json:"Id"`
-			Name        string            `json:"Name"`
-			ProviderIds map[string]string `json:"ProviderIds"`
-		} `json:"Items"`
+		Items []seriesItem `json:"Items"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&result)
@@ -243,11 +966,7 @@ func (c *Client) GetAllSeries() ([]SeriesInfo, error) {
 
 	series := make([]SeriesInfo, len(result.Items))
 	for i, item := range result.Items {
-		series[i] = SeriesInfo{
-			ID:          item.ID,
-			Name:        item.Name,
-			ProviderIDs: item.ProviderIds,
-		}
+		series[i] = item.toSeriesInfo()
 	}
 
 	return series, nil
@@ -255,7 +974,7 @@ func (c *Client) GetAllSeries() ([]SeriesInfo, error) {
 
 // GetEpisodesForSeries retrieves all episodes for a series
 func (c *Client) GetEpisodesForSeries(seriesID string) ([]EpisodeInfo, error) {
-	endpoint := fmt.Sprintf("/Items?userId=%s&ParentId=%s&Recursive=true&IncludeItemTypes=Episode&Fields=ProviderIds,SeriesName,SeasonName,UserData",
+	endpoint := fmt.Sprintf("/Items?userId=%s&ParentId=%s&Recursive=true&IncludeItemTypes=Episode&Fields=Path,ProviderIds,SeriesName,SeasonName,UserData&EnableUserData=true",
 		c.config.UserID, seriesID)
 
 	resp, err := c.makeRequest("GET", endpoint, nil)
@@ -273,9 +992,12 @@ func (c *Client) GetEpisodesForSeries(seriesID string) ([]EpisodeInfo, error) {
 			IndexNumber       int               `json:"IndexNumber"`
 			ParentIndexNumber int               `json:"ParentIndexNumber"`
 			RuntimeTicks      int64             `json:"RunTimeTicks"`
+			LocationType      string            `json:"LocationType"`
+			Path              string            `json:"Path"`
 			ProviderIds       map[string]string `json:"ProviderIds"`
 			UserData          struct {
-				Played bool `json:"Played"`
+				Played     bool      `json:"Played"`
+				PlayedDate time.Time `json:"LastPlayedDate"`
 			} `json:"UserData"`
 		} `json:"Items"`
 	}
@@ -297,17 +1019,38 @@ func (c *Client) GetEpisodesForSeries(seriesID string) ([]EpisodeInfo, error) {
 			RuntimeMinutes: int(item.RuntimeTicks / (60 * 10 * 1000 * 1000)),
 			ProviderIDs:    item.ProviderIds,
 			Played:         item.UserData.Played,
+			Virtual:        item.LocationType == locationTypeVirtual,
+			Path:           item.Path,
+			PlayedDate:     item.UserData.PlayedDate,
+			InvalidNumbers: !validEpisodeNumbers(item.ParentIndexNumber, item.IndexNumber),
 		}
 	}
 
 	return episodes, nil
 }
 
+// Limits for season and episode numbers. Daily shows may use the year as season number
+const (
+	maxSeasonNumber  = 9999
+	maxEpisodeNumber = 99999
+)
+
+// validEpisodeNumbers returns false for numbers that only come from broken metadata, e.g.
+// negative numbers or a missing episode number outside of the specials
+func validEpisodeNumbers(season, episode int) bool {
+	if season < 0 || season > maxSeasonNumber || episode < 0 || episode > maxEpisodeNumber {
+		return false
+	}
+	return episode > 0 || season == 0
+}
+
 // SeriesInfo represents basic series information
 type SeriesInfo struct {
 	ID          string
 	Name        string
 	ProviderIDs map[string]string
+	// UnplayedItemCount is the number of episodes the user has not watched yet, nil if unknown
+	UnplayedItemCount *int
 }
 
 // EpisodeInfo represents episode information
@@ -321,21 +1064,36 @@ type EpisodeInfo struct {
 	RuntimeMinutes int
 	ProviderIDs    map[string]string
 	Played         bool
+	// Virtual is true for placeholders of missing episodes, which have no file
+	Virtual bool
+	Path    string
+	// PlayedDate is when the user last played the episode, zero if unknown
+	PlayedDate time.Time
+	// InvalidNumbers is true if the season or episode number is out of range because of broken metadata
+	InvalidNumbers bool
 }
 
+// locationTypeVirtual is the LocationType of items that only exist as metadata
+const locationTypeVirtual = "Virtual"
+
 // MovieInfo represents movie information with watched status
 type MovieInfo struct {
-	ID     string
-	Played bool
+	ID          string
+	Name        string
+	ProviderIDs map[string]string
+	Played      bool
+	Path        string
+	// PlayedDate is when the user last played the movie, zero if unknown
+	PlayedDate time.Time
 }
 
 // GetAllMovies retrieves all movies with their watched status
-func (c *Client) GetAllMovies() (map[string]MovieInfo, map[string]MovieInfo, error) {
-	endpoint := fmt.Sprintf("/Items?userId=%s&Recursive=true&IncludeItemTypes=Movie&Fields=ProviderIds,UserData", c.config.UserID)
+func (c *Client) GetAllMovies() ([]MovieInfo, error) {
+	endpoint := fmt.Sprintf("/Items?userId=%s&Recursive=true&IncludeItemTypes=Movie&Fields=Path,ProviderIds,UserData&EnableUserData=true", c.config.UserID)
 
 	resp, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
@@ -343,34 +1101,31 @@ func (c *Client) GetAllMovies() (map[string]MovieInfo, map[string]MovieInfo, err
 		Items []struct {
 			ID          string            `json:"Id"`
 			Name        string            `json:"Name"`
+			Path        string            `json:"Path"`
 			ProviderIds map[string]string `json:"ProviderIds"`
 			UserData    struct {
-				Played bool `json:"Played"`
+				Played     bool      `json:"Played"`
+				PlayedDate time.Time `json:"LastPlayedDate"`
 			} `json:"UserData"`
 		} `json:"Items"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
-		return nil, nil, fmt.Errorf("decoding movies response: %w", err)
+		return nil, fmt.Errorf("decoding movies response: %w", err)
 	}
 
-	providerIdMap := make(map[string]MovieInfo)
-	nameMap := make(map[string]MovieInfo)
-
-	for _, item := range result.Items {
-		info := MovieInfo{
-			ID:     item.ID,
-			Played: item.UserData.Played,
-		}
-
-		nameMap[item.Name] = info
-
-		for provider, id := range item.ProviderIds {
-			key := provider + ":" + id
-			providerIdMap[key] = info
+	movies := make([]MovieInfo, len(result.Items))
+	for i, item := range result.Items {
+		movies[i] = MovieInfo{
+			ID:          item.ID,
+			Name:        item.Name,
+			ProviderIDs: item.ProviderIds,
+			Played:      item.UserData.Played,
+			Path:        item.Path,
+			PlayedDate:  item.UserData.PlayedDate,
 		}
 	}
 
-	return providerIdMap, nameMap, nil
+	return movies, nil
 }
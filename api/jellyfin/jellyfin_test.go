@@ -0,0 +1,79 @@
+package jellyfin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// usersTransport answers /Users with the given JSON body and every other request with 404
+type usersTransport string
+
+func (t usersTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusNotFound, ""
+	if req.URL.Path == "/Users" {
+		status, body = http.StatusOK, string(t)
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestNewClientUser(t *testing.T) {
+	const users = `[{"Name": "bob", "Id": "u1"}, {"Name": "", "Id": "u2"}, {"Name": "amy", "Id": ""}]`
+	tests := []struct {
+		name     string
+		userName string
+		// wantErr is part of the error of NewClient, empty if it succeeds
+		wantErr string
+		// wantNoUserID is true if NewClient or RequireUserID return ErrNoUserID
+		wantNoUserID bool
+		wantUserID   string
+	}{
+		{name: "user found", userName: "Bob", wantUserID: "u1"},
+		{name: "empty user name", userName: "", wantNoUserID: true},
+		{name: "blank user name", userName: "  ", wantErr: "no user name given", wantNoUserID: true},
+		{name: "user without ID", userName: "amy", wantErr: "without ID", wantNoUserID: true},
+		{name: "unknown user", userName: "carl", wantErr: "user not found: carl", wantNoUserID: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := models.Config{ServerURL: "http://jf", APIKey: "key", UserName: test.userName}
+			client, err := NewClient(config, WithTransport(usersTransport(users)), WithRetries(0))
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("NewClient() error = %v, want %q", err, test.wantErr)
+			}
+			if client == nil {
+				return
+			}
+			if err := client.RequireUserID(); errors.Is(err, ErrNoUserID) != test.wantNoUserID {
+				t.Errorf("RequireUserID() = %v, want ErrNoUserID: %t", err, test.wantNoUserID)
+			}
+			if got := client.GetConfig().UserID; got != test.wantUserID {
+				t.Errorf("UserID = %q, want %q", got, test.wantUserID)
+			}
+		})
+	}
+}
+
+func TestWithUserEmptyName(t *testing.T) {
+	config := models.Config{ServerURL: "http://jf", APIKey: "key", UserName: "bob"}
+	client, err := NewClient(config, WithTransport(usersTransport(`[{"Name": "", "Id": "u2"}, {"Name": "bob", "Id": "u1"}]`)))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	// The user without name must not be picked for an empty name
+	if _, err := client.WithUser(""); err == nil || !strings.Contains(err.Error(), "no user name given") {
+		t.Errorf("WithUser(\"\") error = %v, want no user name given", err)
+	}
+}
@@ -2,10 +2,14 @@ package tvdb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/forceu/jellyfinmanager/models"
@@ -19,17 +23,61 @@ const (
 type Client struct {
 	apiKey     string
 	token      string
+	language   string
+	userAgent  string
+	ctx        context.Context
 	httpClient *http.Client
+	// seriesCache stores the results of SearchSeriesByTVDBID, as they are requested multiple times per run
+	seriesCache map[string]*SeriesExtended
+}
+
+// Option configures optional settings of a Client
+type Option func(*Client)
+
+// WithTransport sets the RoundTripper that is used for all requests,
+// e.g. to replay recorded interactions instead of contacting the server
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = rt
+	}
+}
+
+// WithLanguage requests episode names and overviews in the given language,
+// e.g. "deu" or "fra". TVDB uses three-letter ISO 639-2 codes
+func WithLanguage(language string) Option {
+	return func(c *Client) {
+		c.language = language
+	}
+}
+
+// WithContext sets the context of all requests, e.g. to stop them once a deadline has passed
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+// WithUserAgent sets the User-Agent header of all requests
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
 }
 
 // NewClient creates a new TVDB API client
-func NewClient(apiKey string) *Client {
-	return &Client{
+func NewClient(apiKey string, options ...Option) *Client {
+	client := &Client{
 		apiKey: apiKey,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		seriesCache: make(map[string]*SeriesExtended),
+		ctx:         context.Background(),
+	}
+	for _, option := range options {
+		option(client)
 	}
+	return client
 }
 
 // Login authenticates with TVDB and gets a bearer token
@@ -43,12 +91,13 @@ func (c *Client) Login() error {
 		return fmt.Errorf("marshaling login payload: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", baseURL+"/login", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(c.ctx, "POST", baseURL+"/login", bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating login request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	c.setUserAgent(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -77,19 +126,31 @@ func (c *Client) Login() error {
 	return nil
 }
 
+// LoggedIn returns true if the client has a token from Login, so a client can be shared
+// between several operations without logging in again
+func (c *Client) LoggedIn() bool {
+	return c.token != ""
+}
+
+// Language returns the language set with WithLanguage, empty if none was set
+func (c *Client) Language() string {
+	return c.language
+}
+
 // makeRequest performs an authenticated request to TVDB API
 func (c *Client) makeRequest(method, endpoint string) (*http.Response, error) {
 	if c.token == "" {
 		return nil, fmt.Errorf("not authenticated - call Login() first")
 	}
 
-	req, err := http.NewRequest(method, baseURL+endpoint, nil)
+	req, err := http.NewRequestWithContext(c.ctx, method, baseURL+endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+c.token)
 	req.Header.Set("Content-Type", "application/json")
+	c.setUserAgent(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -99,8 +160,19 @@ func (c *Client) makeRequest(method, endpoint string) (*http.Response, error) {
 	return resp, nil
 }
 
-// SearchSeriesByTVDBID searches for a series by TVDB ID
+// setUserAgent sets the configured User-Agent. Go's default is kept if none was set
+func (c *Client) setUserAgent(req *http.Request) {
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+}
+
+// SearchSeriesByTVDBID searches for a series by TVDB ID. Results are cached
 func (c *Client) SearchSeriesByTVDBID(tvdbID string) (*SeriesExtended, error) {
+	if series, ok := c.seriesCache[tvdbID]; ok {
+		return series, nil
+	}
+
 	resp, err := c.makeRequest("GET", "/series/"+tvdbID+"/extended")
 	if err != nil {
 		return nil, err
@@ -121,27 +193,135 @@ func (c *Client) SearchSeriesByTVDBID(tvdbID string) (*SeriesExtended, error) {
 		return nil, fmt.Errorf("decoding series response: %w", err)
 	}
 
+	c.seriesCache[tvdbID] = &result.Data
 	return &result.Data, nil
 }
 
-// GetSeriesEpisodes retrieves all episodes for a series
+// GetSeasonsForSeries returns the seasons of all orders of a series, sorted by season type and number
+func (c *Client) GetSeasonsForSeries(tvdbID string) ([]Season, error) {
+	series, err := c.SearchSeriesByTVDBID(tvdbID)
+	if err != nil {
+		return nil, err
+	}
+	seasons := append([]Season(nil), series.Seasons...)
+	sort.SliceStable(seasons, func(i, j int) bool {
+		if seasons[i].Type.ID != seasons[j].Type.ID {
+			return seasons[i].Type.ID < seasons[j].Type.ID
+		}
+		return seasons[i].Number < seasons[j].Number
+	})
+	return seasons, nil
+}
+
+// SeasonNumbersByID maps the TVDB season IDs of the given season type to their season numbers,
+// so episodes can be assigned to their logical season in that order. All types are included if seasonType is empty
+func SeasonNumbersByID(seasons []Season, seasonType string) map[int]int {
+	result := make(map[int]int)
+	for _, season := range seasons {
+		if seasonType != "" && !strings.EqualFold(season.Type.Type, seasonType) {
+			continue
+		}
+		result[season.ID] = season.Number
+	}
+	return result
+}
+
+// alternateSeasonTypes are the orders an episode can be numbered in besides the aired order.
+// The absolute order is left out, it does not have seasons
+var alternateSeasonTypes = map[string]bool{
+	"dvd":       true,
+	"alternate": true,
+	"regional":  true,
+	"altdvd":    true,
+}
+
+// AlternateSeasonTypes returns the season types of the given seasons that number episodes
+// differently than the aired order, without duplicates
+func AlternateSeasonTypes(seasons []Season) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, season := range seasons {
+		seasonType := strings.ToLower(season.Type.Type)
+		if !alternateSeasonTypes[seasonType] || seen[seasonType] {
+			continue
+		}
+		seen[seasonType] = true
+		result = append(result, seasonType)
+	}
+	return result
+}
+
+// SeriesURL returns the link to the page of a series on the TVDB website
+func SeriesURL(slug string) string {
+	return "https://thetvdb.com/series/" + slug
+}
+
+// GetSeriesEpisodes retrieves all episodes for a series. If a language was set,
+// names and overviews are translated where a translation is available
 func (c *Client) GetSeriesEpisodes(seriesID string) ([]Episode, error) {
+	allEpisodes, err := c.getEpisodes(seriesID, "default")
+	if err != nil {
+		return nil, err
+	}
+	if c.language == "" {
+		return allEpisodes, nil
+	}
+
+	translated, err := c.getEpisodes(seriesID, "default/"+c.language)
+	if err != nil {
+		// Keep the default language if there is no translation for this series
+		return allEpisodes, nil
+	}
+	translations := make(map[int]Episode, len(translated))
+	for _, ep := range translated {
+		translations[ep.ID] = ep
+	}
+	for i, ep := range allEpisodes {
+		translation, ok := translations[ep.ID]
+		if !ok {
+			continue
+		}
+		if translation.Name != "" {
+			allEpisodes[i].Name = translation.Name
+		}
+		if translation.Overview != "" {
+			allEpisodes[i].Overview = translation.Overview
+		}
+	}
+	return allEpisodes, nil
+}
+
+// GetSeriesEpisodesInOrder retrieves all episodes for a series numbered in the given season type,
+// e.g. "dvd". Names and overviews are not translated
+func (c *Client) GetSeriesEpisodesInOrder(seriesID, seasonType string) ([]Episode, error) {
+	return c.getEpisodes(seriesID, seasonType)
+}
+
+// getEpisodes retrieves all pages of episodes for a series with the given season type and language path
+func (c *Client) getEpisodes(seriesID, seasonTypePath string) ([]Episode, error) {
 	var allEpisodes []Episode
 	page := 0
+	total := 0
 
 	for {
-		endpoint := fmt.Sprintf("/series/%s/episodes/default?page=%d", seriesID, page)
+		endpoint := fmt.Sprintf("/series/%s/episodes/%s?page=%d", seriesID, seasonTypePath, page)
 		resp, err := c.makeRequest("GET", endpoint)
 		if err != nil {
 			return nil, err
 		}
 
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("episodes not found (status %d)", resp.StatusCode)
+		}
+
 		var result struct {
 			Data struct {
 				Episodes []Episode `json:"episodes"`
 			} `json:"data"`
 			Links struct {
-				Next string `json:"next"`
+				Next       string `json:"next"`
+				TotalItems int    `json:"total_items"`
 			} `json:"links"`
 			Status string `json:"status"`
 		}
@@ -154,6 +334,7 @@ func (c *Client) GetSeriesEpisodes(seriesID string) ([]Episode, error) {
 		resp.Body.Close()
 
 		allEpisodes = append(allEpisodes, result.Data.Episodes...)
+		total = max(total, result.Links.TotalItems)
 
 		// Check if there are more pages
 		if result.Links.Next == "" {
@@ -162,9 +343,42 @@ func (c *Client) GetSeriesEpisodes(seriesID string) ([]Episode, error) {
 		page++
 	}
 
+	if len(allEpisodes) < total {
+		return nil, fmt.Errorf("%w: received %d of %d episodes", ErrIncompleteEpisodes, len(allEpisodes), total)
+	}
 	return allEpisodes, nil
 }
 
+// ErrIncompleteEpisodes is returned if TVDB returned fewer episodes than it reported
+var ErrIncompleteEpisodes = errors.New("incomplete episode data from TVDB")
+
+// officialSeasonType is the season type of the aired order
+const officialSeasonType = "official"
+
+// EmptySeasons returns the numbers of the regular seasons in the aired order that have no
+// episodes, although a later season has. This happens if TVDB returned incomplete data.
+// Seasons after the last one with episodes are left out, as they may not have aired yet
+func EmptySeasons(seasons []Season, episodes []Episode) []int {
+	withEpisodes := make(map[int]bool)
+	lastSeason := 0
+	for _, ep := range episodes {
+		withEpisodes[ep.SeasonNumber] = true
+		lastSeason = max(lastSeason, ep.SeasonNumber)
+	}
+
+	var empty []int
+	for _, season := range seasons {
+		if !strings.EqualFold(season.Type.Type, officialSeasonType) {
+			continue
+		}
+		if season.Number > 0 && season.Number < lastSeason && !withEpisodes[season.Number] {
+			empty = append(empty, season.Number)
+		}
+	}
+	sort.Ints(empty)
+	return empty
+}
+
 // SeriesExtended represents extended series information from TVDB
 type SeriesExtended struct {
 	ID                int      `json:"id"`
@@ -181,14 +395,17 @@ type SeriesExtended struct {
 
 // Season represents a season from TVDB
 type Season struct {
-	ID     int    `json:"id"`
-	Number int    `json:"number"`
-	Name   string `json:"name"`
-	Type   struct {
-		ID   int    `json:"id"`
-		Name string `json:"name"`
-		Type string `json:"type"`
-	} `json:"type"`
+	ID     int        `json:"id"`
+	Number int        `json:"number"`
+	Name   string     `json:"name"`
+	Type   SeasonType `json:"type"`
+}
+
+// SeasonType is the order a season belongs to, e.g. "official" (aired), "dvd" or "absolute"
+type SeasonType struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
 // Status represents a series status
@@ -209,11 +426,58 @@ type Episode struct {
 	SeriesID       int    `json:"seriesId"`
 	AbsoluteNumber int    `json:"absoluteNumber"`
 	FinaleType     string `json:"finaleType"`
+	IsMovie        int    `json:"isMovie"`
+}
+
+// movieSeasonNumbers returns the numbers of the seasons in the aired order that TVDB uses to
+// group movies. Seasons of other orders are ignored, as their numbers differ from the episodes
+func movieSeasonNumbers(seasons []Season) map[int]bool {
+	result := make(map[int]bool)
+	for _, season := range seasons {
+		if !strings.EqualFold(season.Type.Type, officialSeasonType) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(season.Name), "movie") {
+			result[season.Number] = true
+		}
+	}
+	return result
+}
+
+// FilterMovieSpecials removes all episodes in the aired order that are flagged as movies by TVDB.
+// An episode is considered a movie if it has the isMovie flag set or if it
+// belongs to an aired order season that TVDB labels as movies
+func FilterMovieSpecials(episodes []Episode, seasons []Season) []Episode {
+	movieSeasons := movieSeasonNumbers(seasons)
+	filtered := make([]Episode, 0, len(episodes))
+	for _, ep := range episodes {
+		if ep.IsMovie != 0 {
+			continue
+		}
+		if movieSeasons[ep.SeasonNumber] {
+			continue
+		}
+		filtered = append(filtered, ep)
+	}
+	return filtered
+}
+
+// FilterSeasons removes all episodes that are not part of the given seasons
+func FilterSeasons(episodes []Episode, seasons map[int]bool) []Episode {
+	filtered := make([]Episode, 0, len(episodes))
+	for _, ep := range episodes {
+		if seasons[ep.SeasonNumber] {
+			filtered = append(filtered, ep)
+		}
+	}
+	return filtered
 }
 
 // FindMissingEpisodes finds episodes that are missing from Jellyfin
-// It also excludes multi-part episodes that appear merged based on runtime analysis
-func FindMissingEpisodes(tvdbEpisodes []Episode, jellyfinEpisodes map[string]int, checkSpecials bool) []models.MissingEpisode {
+// It also excludes multi-part episodes that appear merged based on runtime analysis.
+// defaultRuntime is used for episodes without a runtime on TVDB, 0 keeps them at 0 minutes.
+// If crossSeasonMerge is set, a file may also contain episodes of the following season, but not of later ones
+func FindMissingEpisodes(tvdbEpisodes []Episode, jellyfinEpisodes map[string]int, checkSpecials bool, defaultRuntime int, crossSeasonMerge bool) []models.MissingEpisode {
 	var missing []models.MissingEpisode
 
 	// State variables to track merging of multi-part episodes
@@ -222,6 +486,8 @@ func FindMissingEpisodes(tvdbEpisodes []Episode, jellyfinEpisodes map[string]int
 		chainSeason           int
 		chainJfRuntime        int // Actual runtime of the file in Jellyfin
 		chainTvdbRuntimeAccum int // Expected runtime (sum of TVDB episodes in this chain)
+		// chainCrossed is set once the chain continued into the next season
+		chainCrossed bool
 	)
 
 	for _, ep := range tvdbEpisodes {
@@ -231,13 +497,19 @@ func FindMissingEpisodes(tvdbEpisodes []Episode, jellyfinEpisodes map[string]int
 		airDate, errDate := time.Parse("2006-01-02", ep.Aired)
 		jfRuntime, episodeStored := jellyfinEpisodes[key]
 
+		tvdbRuntime := ep.RuntimeMinutes
+		if tvdbRuntime == 0 {
+			tvdbRuntime = defaultRuntime
+		}
+
 		if episodeStored {
 			// Episode found in Jellyfin.
 			// Start a new chain: this file might contain subsequent missing episodes.
 			chainActive = true
 			chainSeason = ep.SeasonNumber
+			chainCrossed = false
 			chainJfRuntime = jfRuntime
-			chainTvdbRuntimeAccum = ep.RuntimeMinutes
+			chainTvdbRuntimeAccum = tvdbRuntime
 		} else {
 			// Episode NOT found in Jellyfin.
 			// Check if it is a valid candidate for being reported as missing.
@@ -248,15 +520,21 @@ func FindMissingEpisodes(tvdbEpisodes []Episode, jellyfinEpisodes map[string]int
 
 				// CHECK MERGE CONDITION:
 				// 1. We must have a valid previous episode (chainActive)
-				// 2. It must be in the same season
-				if chainActive && chainSeason == ep.SeasonNumber {
+				// 2. It must be in the same season, or in the next one if merges across seasons are allowed
+				crossesSeason := crossSeasonMerge && !chainCrossed && ep.SeasonNumber == chainSeason+1
+				if chainActive && (ep.SeasonNumber == chainSeason || crossesSeason) {
 					// Add current episode's expected length to the accumulator
-					chainTvdbRuntimeAccum += ep.RuntimeMinutes
+					chainTvdbRuntimeAccum += tvdbRuntime
 
 					// Check if the file on disk is at least 85% of the total expected length
 					requiredLength := float64(chainTvdbRuntimeAccum)
 					if float64(chainJfRuntime) >= requiredLength*0.85 {
 						isMerged = true
+						// The chain continues in the new season, but cannot step into another one
+						if crossesSeason {
+							chainSeason = ep.SeasonNumber
+							chainCrossed = true
+						}
 					} else {
 						// The file is not long enough to include this episode.
 						// The chain is broken.
@@ -270,11 +548,13 @@ func FindMissingEpisodes(tvdbEpisodes []Episode, jellyfinEpisodes map[string]int
 				// If not merged, mark as missing
 				if !isMerged {
 					missing = append(missing, models.MissingEpisode{
-						SeasonNumber:  ep.SeasonNumber,
-						EpisodeNumber: ep.Number,
-						EpisodeName:   ep.Name,
-						AirDate:       ep.Aired,
-						Overview:      ep.Overview,
+						TvdbID:         ep.ID,
+						SeasonNumber:   ep.SeasonNumber,
+						EpisodeNumber:  ep.Number,
+						EpisodeName:    ep.Name,
+						AirDate:        ep.Aired,
+						Overview:       ep.Overview,
+						RuntimeMinutes: ep.RuntimeMinutes,
 					})
 					// A missing episode breaks the chain for subsequent episodes
 					chainActive = false
@@ -0,0 +1,113 @@
+package tvdb
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFindMissingEpisodesMerge(t *testing.T) {
+	episode := func(season, number int) Episode {
+		return Episode{SeasonNumber: season, Number: number, Aired: "2020-01-01", RuntimeMinutes: 30}
+	}
+	tests := []struct {
+		name             string
+		episodes         []Episode
+		jellyfin         map[string]int
+		crossSeasonMerge bool
+		want             []string
+	}{
+		{
+			name:     "same season merge",
+			episodes: []Episode{episode(1, 1), episode(1, 2), episode(1, 3)},
+			jellyfin: map[string]int{"1:1": 60},
+			want:     []string{"1:3"},
+		},
+		{
+			name:             "merge into the next season",
+			episodes:         []Episode{episode(1, 1), episode(2, 1), episode(2, 2)},
+			jellyfin:         map[string]int{"1:1": 60},
+			crossSeasonMerge: true,
+			want:             []string{"2:2"},
+		},
+		{
+			name:     "merge into the next season without flag",
+			episodes: []Episode{episode(1, 1), episode(2, 1), episode(2, 2)},
+			jellyfin: map[string]int{"1:1": 60},
+			want:     []string{"2:1", "2:2"},
+		},
+		{
+			name:             "chain skipping a season",
+			episodes:         []Episode{episode(1, 1), episode(3, 1)},
+			jellyfin:         map[string]int{"1:1": 90},
+			crossSeasonMerge: true,
+			want:             []string{"3:1"},
+		},
+		{
+			name:             "chain stepping into a second season",
+			episodes:         []Episode{episode(1, 1), episode(2, 1), episode(3, 1)},
+			jellyfin:         map[string]int{"1:1": 90},
+			crossSeasonMerge: true,
+			want:             []string{"3:1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, missing := range FindMissingEpisodes(test.episodes, test.jellyfin, false, 0, test.crossSeasonMerge) {
+				got = append(got, fmt.Sprintf("%d:%d", missing.SeasonNumber, missing.EpisodeNumber))
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("missing episodes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFilterMovieSpecials(t *testing.T) {
+	official := SeasonType{Name: "Aired Order", Type: "official"}
+	dvd := SeasonType{Name: "DVD Order", Type: "dvd"}
+	tests := []struct {
+		name    string
+		seasons []Season
+		want    []string
+	}{
+		{
+			name:    "movie season in the aired order",
+			seasons: []Season{{Number: 0, Name: "Movies", Type: official}},
+			want:    []string{"1:1", "2:1"},
+		},
+		{
+			name:    "movie season in another order",
+			seasons: []Season{{Number: 2, Name: "Movies", Type: dvd}},
+			want:    []string{"0:1", "1:1", "2:1"},
+		},
+		{
+			name:    "movie in the name of the season type",
+			seasons: []Season{{Number: 1, Name: "Season 1", Type: SeasonType{Name: "Movie Order", Type: "alternate"}}},
+			want:    []string{"0:1", "1:1", "2:1"},
+		},
+		{
+			name: "without seasons",
+			want: []string{"0:1", "1:1", "2:1"},
+		},
+	}
+	episodes := []Episode{
+		{SeasonNumber: 0, Number: 1},
+		{SeasonNumber: 1, Number: 1},
+		{SeasonNumber: 2, Number: 1},
+		// Flagged movies are always removed
+		{SeasonNumber: 2, Number: 2, IsMovie: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, ep := range FilterMovieSpecials(episodes, test.seasons) {
+				got = append(got, fmt.Sprintf("%d:%d", ep.SeasonNumber, ep.Number))
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("episodes = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
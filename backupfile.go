@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// gzipExtension is the file extension that enables compression of the backup
+const gzipExtension = ".gz"
+
+// Supported file formats of a backup
+const (
+	formatJSON = "json"
+	formatXML  = "xml"
+)
+
+// defaultCompressLevel is the gzip level used if none is given. It matches the level
+// gzip.DefaultCompression currently stands for, but can be shown in the help text
+const defaultCompressLevel = 6
+
+// maxSymlinkDepth limits how many symlinks are followed, to detect loops
+const maxSymlinkDepth = 40
+
+// prepareBackupPath resolves symlinks in the backup file path and creates the parent
+// directory if it does not exist yet. It returns the path the backup should be written to.
+// Unlike filepath.EvalSymlinks, this also works for links pointing to files that do not exist yet
+func prepareBackupPath(filename string) (string, error) {
+	for i := 0; ; i++ {
+		info, err := os.Lstat(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("checking backup file: %w", err)
+		}
+		if info.IsDir() {
+			return "", fmt.Errorf("backup file %s is a directory", filename)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			break
+		}
+		if i == maxSymlinkDepth {
+			return "", fmt.Errorf("too many levels of symlinks for backup file %s", filename)
+		}
+		target, err := os.Readlink(filename)
+		if err != nil {
+			return "", fmt.Errorf("resolving symlink %s: %w", filename, err)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(filename), target)
+		}
+		filename = target
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return "", fmt.Errorf("creating backup directory: %w", err)
+	}
+	return filename, nil
+}
+
+// writeBackupFile writes the backup to a file. If the file name ends with .gz,
+// the data is compressed with the given gzip level
+func writeBackupFile(filename string, data []byte, compressLevel int) error {
+	if !strings.HasSuffix(strings.ToLower(filename), gzipExtension) {
+		return os.WriteFile(filename, data, 0644)
+	}
+
+	var buffer bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buffer, compressLevel)
+	if err != nil {
+		return fmt.Errorf("creating gzip writer: %w", err)
+	}
+	if _, err := writer.Write(data); err != nil {
+		return fmt.Errorf("compressing backup: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("compressing backup: %w", err)
+	}
+	return os.WriteFile(filename, buffer.Bytes(), 0644)
+}
+
+// readBackupFile reads a backup file and decompresses it if it is gzip compressed.
+// Compression is detected by content, so renamed files can still be read
+func readBackupFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip data: %w", err)
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// parseBackupFormat validates the requested format. If no format is given, it is
+// detected from the file extension, e.g. backup.xml or backup.xml.gz
+func parseBackupFormat(format, filename string) (string, error) {
+	switch strings.ToLower(format) {
+	case formatJSON:
+		return formatJSON, nil
+	case formatXML:
+		return formatXML, nil
+	case "":
+		name := strings.TrimSuffix(strings.ToLower(filename), gzipExtension)
+		if strings.HasSuffix(name, ".xml") {
+			return formatXML, nil
+		}
+		return formatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported backup format: %s", format)
+	}
+}
+
+// marshalBackup serialises the backup in the given format. If compact is true,
+// the output is not indented
+func marshalBackup(backup models.Backup, format string, compact bool) ([]byte, error) {
+	if format == formatXML {
+		var data []byte
+		var err error
+		if compact {
+			data, err = xml.Marshal(backup)
+		} else {
+			data, err = xml.MarshalIndent(backup, "", "  ")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(xml.Header), data...), nil
+	}
+	if compact {
+		return json.Marshal(backup)
+	}
+	return json.MarshalIndent(backup, "", "  ")
+}
+
+// unmarshalBackup parses a backup. The format is detected by content,
+// so restore does not need to know which format was used for the backup
+func unmarshalBackup(data []byte, backup *models.Backup) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return xml.Unmarshal(data, backup)
+	}
+	return json.Unmarshal(data, backup)
+}
+
+// loadBackup reads and parses a backup file
+func loadBackup(filename string) (models.Backup, error) {
+	var backup models.Backup
+	data, err := readBackupFile(filename)
+	if err != nil {
+		return backup, fmt.Errorf("reading backup file: %w", err)
+	}
+	if err := unmarshalBackup(data, &backup); err != nil {
+		return backup, fmt.Errorf("unmarshaling backup: %w", err)
+	}
+	return backup, nil
+}
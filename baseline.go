@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// baselineDiff holds the changes of the missing episodes since an earlier report
+type baselineDiff struct {
+	// Resolved are missing in the earlier report, but not anymore
+	Resolved []models.MissingEpisode
+	// New are missing now, but not in the earlier report
+	New []models.MissingEpisode
+	// Unchanged is the number of episodes that are missing in both reports
+	Unchanged int
+}
+
+// loadBaseline reads a JSON report of an earlier run for -baseline
+func loadBaseline(filename string) (missingReport, error) {
+	var baseline missingReport
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return baseline, fmt.Errorf("reading baseline: %w", err)
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("parsing baseline, it must be a JSON report written with -report-file: %w", err)
+	}
+	return baseline, nil
+}
+
+// diffWithBaseline compares the missing episodes of both reports by their TVDB ID.
+// Episodes of series that were not checked in the current run, e.g. because of an error
+// or an interruption, are neither resolved nor unchanged
+func diffWithBaseline(baseline, current missingReport) baselineDiff {
+	var diff baselineDiff
+	before := make(map[string]bool)
+	for _, result := range baseline.Series {
+		for _, m := range result.Missing {
+			before[missingEpisodeKey(result.TvdbID, m)] = true
+		}
+	}
+
+	now := make(map[string]bool)
+	for _, result := range current.Series {
+		for _, m := range result.Missing {
+			key := missingEpisodeKey(result.TvdbID, m)
+			now[key] = true
+			if before[key] {
+				diff.Unchanged++
+				continue
+			}
+			m.SeriesName = result.SeriesName
+			diff.New = append(diff.New, m)
+		}
+	}
+
+	for _, result := range baseline.Series {
+		if !current.checked[result.TvdbID] {
+			continue
+		}
+		for _, m := range result.Missing {
+			if now[missingEpisodeKey(result.TvdbID, m)] {
+				continue
+			}
+			m.SeriesName = result.SeriesName
+			diff.Resolved = append(diff.Resolved, m)
+		}
+	}
+	return diff
+}
+
+// printBaselineDiff prints the episodes that were resolved and that are newly missing
+func printBaselineDiff(baseline missingReport, diff baselineDiff, episodeFormat string) {
+	fmt.Printf("\n=== Changes Since %s ===\n", baseline.CreatedAt.Format("2006-01-02 15:04"))
+	formatter := newEpisodeFormatter(episodeFormat, append(append([]models.MissingEpisode(nil), diff.Resolved...), diff.New...))
+	fmt.Printf("✓ Resolved: %d\n", len(diff.Resolved))
+	for _, m := range diff.Resolved {
+		fmt.Printf("    ✓ %s - %s: %s\n", m.SeriesName, formatter.format(m.SeasonNumber, m.EpisodeNumber), m.EpisodeName)
+	}
+	fmt.Printf("+ Newly missing: %d\n", len(diff.New))
+	for _, m := range diff.New {
+		fmt.Printf("    + %s - %s: %s\n", m.SeriesName, formatter.format(m.SeasonNumber, m.EpisodeNumber), m.EpisodeName)
+	}
+	fmt.Printf("○ Unchanged: %d\n", diff.Unchanged)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+)
+
+// findMissingCheckpoint stores the progress of finding missing episodes, so that
+// an interrupted scan can be resumed
+type findMissingCheckpoint struct {
+	// SeriesIDs are the IDs of all series in the library when the scan was started
+	SeriesIDs []string `json:"series_ids"`
+	// Results are the results of all series that were already processed, by series ID
+	Results map[string]seriesResult `json:"results"`
+}
+
+// newCheckpoint creates an empty checkpoint for the given series
+func newCheckpoint(series []jellyfin.SeriesInfo) *findMissingCheckpoint {
+	return &findMissingCheckpoint{
+		SeriesIDs: seriesIDs(series),
+		Results:   make(map[string]seriesResult),
+	}
+}
+
+// seriesIDs returns the sorted IDs of all series
+func seriesIDs(series []jellyfin.SeriesInfo) []string {
+	ids := make([]string, len(series))
+	for i, s := range series {
+		ids[i] = s.ID
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// resumeCheckpoint loads the saved progress. If it cannot be read or the library
+// has changed since it was saved, a new checkpoint is returned
+func resumeCheckpoint(filename string, series []jellyfin.SeriesInfo) *findMissingCheckpoint {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("⚠ Could not read progress file, starting from the beginning: %v\n", err)
+		return newCheckpoint(series)
+	}
+
+	var checkpoint findMissingCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		fmt.Printf("⚠ Could not parse progress file, starting from the beginning: %v\n", err)
+		return newCheckpoint(series)
+	}
+
+	if !slices.Equal(checkpoint.SeriesIDs, seriesIDs(series)) {
+		fmt.Println("⚠ Library has changed since the progress was saved, starting from the beginning")
+		return newCheckpoint(series)
+	}
+	if checkpoint.Results == nil {
+		checkpoint.Results = make(map[string]seriesResult)
+	}
+	fmt.Printf("✓ Resuming, %d series were already processed\n", len(checkpoint.Results))
+	return &checkpoint
+}
+
+// save writes the checkpoint to a temporary file first and then renames it,
+// so that an interruption while writing does not corrupt the saved progress
+func (c *findMissingCheckpoint) save(filename string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshaling progress: %w", err)
+	}
+	tempFile := filename + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, filename)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// chunker pauses after every Size items that were marked as watched, so that
+// servers that lock their database under sustained writes can catch up
+type chunker struct {
+	Size  int
+	Pause time.Duration
+	count int
+}
+
+// wait is called before every write. It pauses once a chunk is complete,
+// or returns early if ctx is done. A nil chunker never pauses
+func (c *chunker) wait(ctx context.Context) {
+	if c == nil || c.Size <= 0 {
+		return
+	}
+	if c.count > 0 && c.count%c.Size == 0 && c.Pause > 0 {
+		fmt.Printf("  ○ Pausing for %s after %d items\n", c.Pause, c.count)
+		select {
+		case <-time.After(c.Pause):
+		case <-ctx.Done():
+		}
+	}
+	c.count++
+}
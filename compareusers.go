@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// userComparison holds the items only one of two users has watched
+type userComparison struct {
+	UserA string               `json:"user_a"`
+	UserB string               `json:"user_b"`
+	OnlyA []models.WatchedItem `json:"only_a"`
+	OnlyB []models.WatchedItem `json:"only_b"`
+}
+
+// parseUserPair splits the value of -compare-users into two user names
+func parseUserPair(value string) (string, string, error) {
+	userA, userB, ok := strings.Cut(value, ",")
+	userA, userB = strings.TrimSpace(userA), strings.TrimSpace(userB)
+	if !ok || userA == "" || userB == "" || strings.Contains(userB, ",") {
+		return "", "", errors.New("-compare-users expects two user names, e.g. alice,bob")
+	}
+	if strings.EqualFold(userA, userB) {
+		return "", "", errors.New("-compare-users expects two different users")
+	}
+	return userA, userB, nil
+}
+
+// performCompareUsers prints the items that one user has watched and the other has not.
+// Nothing is changed on the server
+func performCompareUsers(client *jellyfin.Client, userA, userB, format string) error {
+	itemsA, err := watchedItemsOfUser(client, userA)
+	if err != nil {
+		return err
+	}
+	itemsB, err := watchedItemsOfUser(client, userB)
+	if err != nil {
+		return err
+	}
+
+	comparison := userComparison{
+		UserA: userA,
+		UserB: userB,
+		OnlyA: watchedItemsMissingFrom(itemsA, itemsB),
+		OnlyB: watchedItemsMissingFrom(itemsB, itemsA),
+	}
+
+	recordCount("only_first_user", len(comparison.OnlyA))
+	recordCount("only_second_user", len(comparison.OnlyB))
+
+	if format == reportJSON {
+		data, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling comparison: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	printComparedItems(userA, userB, comparison.OnlyA)
+	printComparedItems(userB, userA, comparison.OnlyB)
+	return nil
+}
+
+// watchedItemsOfUser fetches the watched items of the given user
+func watchedItemsOfUser(client *jellyfin.Client, userName string) ([]models.WatchedItem, error) {
+	userClient, err := client.WithUser(userName)
+	if err != nil {
+		return nil, fmt.Errorf("finding user %s: %w", userName, err)
+	}
+	items, err := userClient.GetWatchedItems()
+	if err != nil {
+		return nil, fmt.Errorf("getting watched items of %s: %w", userName, err)
+	}
+	return items, nil
+}
+
+// watchedItemsMissingFrom returns the items that are not in other. Both users are on the same
+// server and share its library, so the items are identified by their ID
+func watchedItemsMissingFrom(items, other []models.WatchedItem) []models.WatchedItem {
+	watched := make(map[string]bool, len(other))
+	for _, item := range other {
+		watched[item.ID] = true
+	}
+	var missing []models.WatchedItem
+	for _, item := range items {
+		if !watched[item.ID] {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+// printComparedItems lists the items that watchedBy has watched and notBy has not
+func printComparedItems(watchedBy, notBy string, items []models.WatchedItem) {
+	fmt.Printf("\n=== Watched by %s, not by %s (%d) ===\n", watchedBy, notBy, len(items))
+	for _, item := range items {
+		fmt.Printf("  + %s\n", itemDisplayName(item))
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// CSV columns for -import-csv
+const (
+	csvProviderID   = "provider_id"
+	csvName         = "name"
+	csvWatched      = "watched"
+	csvFavorite     = "favorite"
+	csvRating       = "rating"
+	csvType         = "type"
+	csvSeriesName   = "series_name"
+	csvSeasonNumber = "season_number"
+)
+
+// requiredCSVColumns must be present in the header of an import file
+var requiredCSVColumns = []string{csvProviderID, csvName, csvWatched, csvFavorite, csvRating}
+
+// optionalCSVColumns may be present in the header of an import file, e.g. for episodes
+var optionalCSVColumns = []string{csvType, csvSeriesName, csvSeasonNumber}
+
+// csvRow is a movie or episode and the state it should have in Jellyfin
+type csvRow struct {
+	Item     models.WatchedItem
+	Watched  bool
+	Favorite bool
+	// Rating is nil if the row has no rating
+	Rating *float64
+}
+
+// readImportCSV parses an import file. The header must contain all required columns and
+// must not contain unknown ones. Provider IDs are written as provider:id, e.g. imdb:tt0133093,
+// several IDs are separated by semicolons
+func readImportCSV(filename string) ([]csvRow, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening import file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns, err := parseCSVHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []csvRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row, err := parseCSVRecord(record, columns, line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseCSVHeader returns the index of every column and rejects unknown, duplicate and missing columns
+func parseCSVHeader(header []string) (map[string]int, error) {
+	known := make(map[string]bool)
+	for _, column := range requiredCSVColumns {
+		known[column] = true
+	}
+	for _, column := range optionalCSVColumns {
+		known[column] = true
+	}
+
+	columns := make(map[string]int)
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !known[column] {
+			return nil, fmt.Errorf("unknown column: %s", column)
+		}
+		if _, exists := columns[column]; exists {
+			return nil, fmt.Errorf("duplicate column: %s", column)
+		}
+		columns[column] = i
+	}
+	for _, column := range requiredCSVColumns {
+		if _, exists := columns[column]; !exists {
+			return nil, fmt.Errorf("missing column: %s", column)
+		}
+	}
+	return columns, nil
+}
+
+// parseCSVRecord converts a record to a csvRow. The line number is used as the ID of the item
+func parseCSVRecord(record []string, columns map[string]int, line int) (csvRow, error) {
+	value := func(column string) string {
+		i, exists := columns[column]
+		if !exists || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row := csvRow{
+		Item: models.WatchedItem{
+			ID:          strconv.Itoa(line),
+			Name:        value(csvName),
+			Type:        models.TypeMovie,
+			SeriesName:  value(csvSeriesName),
+			ProviderIDs: make(models.ProviderIDs),
+		},
+	}
+	if row.Item.Name == "" {
+		return row, errors.New("name is empty")
+	}
+
+	switch strings.ToLower(value(csvType)) {
+	case "", "movie":
+	case "episode":
+		row.Item.Type = models.TypeEpisode
+		if row.Item.SeriesName == "" {
+			return row, errors.New("series_name is required for episodes")
+		}
+	default:
+		return row, fmt.Errorf("unknown type: %s", value(csvType))
+	}
+
+	if season := value(csvSeasonNumber); season != "" {
+		number, err := strconv.Atoi(season)
+		if err != nil {
+			return row, fmt.Errorf("invalid season_number: %s", season)
+		}
+		row.Item.SeasonNumber = &number
+	}
+
+	for _, providerID := range strings.Split(value(csvProviderID), ";") {
+		if strings.TrimSpace(providerID) == "" {
+			continue
+		}
+		provider, id, ok := strings.Cut(providerID, ":")
+		if !ok || strings.TrimSpace(id) == "" {
+			return row, fmt.Errorf("invalid provider_id, expected provider:id: %s", providerID)
+		}
+		row.Item.ProviderIDs[models.NormalizeProviderName(provider)] = strings.TrimSpace(id)
+	}
+
+	var err error
+	if row.Watched, err = parseCSVBool(value(csvWatched)); err != nil {
+		return row, fmt.Errorf("invalid watched: %w", err)
+	}
+	if row.Favorite, err = parseCSVBool(value(csvFavorite)); err != nil {
+		return row, fmt.Errorf("invalid favorite: %w", err)
+	}
+	if rating := value(csvRating); rating != "" {
+		number, err := strconv.ParseFloat(rating, 64)
+		if err != nil || number < 0 || number > 10 {
+			return row, fmt.Errorf("invalid rating, expected 0 to 10: %s", rating)
+		}
+		row.Rating = &number
+	}
+	return row, nil
+}
+
+// parseCSVBool accepts the usual spellings of a boolean in spreadsheets. An empty cell is false
+func parseCSVBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "0", "false", "no", "n":
+		return false, nil
+	case "1", "true", "yes", "y", "x":
+		return true, nil
+	default:
+		return false, fmt.Errorf("not a boolean: %s", value)
+	}
+}
+
+// performImportCSV matches the rows of a CSV file with the library like a restore and then
+// marks them as watched, as favorites and sets their rating
+func performImportCSV(client *jellyfin.Client, filename string) error {
+	rows, err := readImportCSV(filename)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", filename, err)
+	}
+
+	items := make([]models.WatchedItem, len(rows))
+	for i, row := range rows {
+		items[i] = row.Item
+	}
+
+	// Only match the items first, the changes depend on the columns of each row
+	matches := make(map[string]libraryItem)
+	options := restoreOptions{
+		Pending: &pendingChanges{},
+		OnMatch: func(item models.WatchedItem, info libraryItem) {
+			matches[item.ID] = info
+		},
+	}
+	fmt.Printf("Matching %d items from %s for %s\n", len(rows), filename, client.GetConfig().UserName)
+	restoreItems(client, items, options)
+
+	fmt.Printf("\n=== Importing %d Matched Items ===\n", len(matches))
+	var watched, favorites, ratings, failed int
+	for _, row := range rows {
+		info, found := matches[row.Item.ID]
+		if !found {
+			continue
+		}
+		name := itemDisplayName(row.Item)
+		if row.Watched && !info.Played {
+			if err := client.MarkAsWatched(info.ID); err != nil {
+				fmt.Printf("  ✗ %s - failed to mark as watched: %v\n", name, err)
+				failed++
+			} else {
+				watched++
+			}
+		}
+		if row.Favorite {
+			if err := client.SetFavorite(info.ID, true); err != nil {
+				fmt.Printf("  ✗ %s - failed to mark as favorite: %v\n", name, err)
+				failed++
+			} else {
+				favorites++
+			}
+		}
+		if row.Rating != nil {
+			if err := client.SetRating(info.ID, *row.Rating); err != nil {
+				fmt.Printf("  ✗ %s - failed to set rating: %v\n", name, err)
+				failed++
+			} else {
+				ratings++
+			}
+		}
+	}
+
+	recordCount("not_found", len(rows)-len(matches))
+	recordCount("watched", watched)
+	recordCount("favorites", favorites)
+	recordCount("ratings", ratings)
+	recordCount("failed", failed)
+
+	fmt.Printf("\n=== Import Complete ===\n")
+	fmt.Printf("Not found: %d\n", len(rows)-len(matches))
+	fmt.Printf("Marked as watched: %d\n", watched)
+	fmt.Printf("Marked as favorite: %d\n", favorites)
+	fmt.Printf("Ratings set: %d\n", ratings)
+	fmt.Printf("Failed: %d\n", failed)
+	return nil
+}
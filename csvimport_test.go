@@ -0,0 +1,151 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+func TestParseCSVHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    map[string]int
+		wantErr string
+	}{
+		{
+			name:   "required columns",
+			header: "provider_id,name,watched,favorite,rating",
+			want:   map[string]int{csvProviderID: 0, csvName: 1, csvWatched: 2, csvFavorite: 3, csvRating: 4},
+		},
+		{
+			name:   "optional columns in any case",
+			header: "Name, Provider_ID,watched,favorite,rating,type",
+			want:   map[string]int{csvName: 0, csvProviderID: 1, csvWatched: 2, csvFavorite: 3, csvRating: 4, csvType: 5},
+		},
+		{name: "unknown column", header: "provider_id,name,watched,favorite,rating,year", wantErr: "unknown column: year"},
+		{name: "duplicate column", header: "provider_id,name,watched,favorite,rating,name", wantErr: "duplicate column: name"},
+		{name: "missing column", header: "provider_id,name,watched,favorite", wantErr: "missing column: rating"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			columns, err := parseCSVHeader(strings.Split(test.header, ","))
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(columns) != len(test.want) {
+				t.Fatalf("columns = %v, want %v", columns, test.want)
+			}
+			for column, index := range test.want {
+				if columns[column] != index {
+					t.Errorf("columns = %v, want %v", columns, test.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseCSVRecord(t *testing.T) {
+	columns, err := parseCSVHeader([]string{"provider_id", "name", "watched", "favorite", "rating", "type", "series_name", "season_number"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		record  string
+		check   func(t *testing.T, row csvRow)
+		wantErr string
+	}{
+		{
+			name:   "movie with several provider IDs",
+			record: "imdb:tt0133093; Tmdb:603,The Matrix,yes,x,8.5,,,",
+			check: func(t *testing.T, row csvRow) {
+				if row.Item.Type != models.TypeMovie || row.Item.Name != "The Matrix" || row.Item.ID != "2" {
+					t.Errorf("item = %+v", row.Item)
+				}
+				if row.Item.ProviderIDs[models.ProviderImdb] != "tt0133093" || row.Item.ProviderIDs[models.ProviderTmdb] != "603" {
+					t.Errorf("provider IDs = %v", row.Item.ProviderIDs)
+				}
+				if !row.Watched || !row.Favorite || row.Rating == nil || *row.Rating != 8.5 {
+					t.Errorf("row = %+v", row)
+				}
+			},
+		},
+		{
+			name:   "episode without rating",
+			record: ",Pilot,1,no,,Episode,Lost,1",
+			check: func(t *testing.T, row csvRow) {
+				if row.Item.Type != models.TypeEpisode || row.Item.SeriesName != "Lost" {
+					t.Errorf("item = %+v", row.Item)
+				}
+				if row.Item.SeasonNumber == nil || *row.Item.SeasonNumber != 1 {
+					t.Errorf("season number = %v", row.Item.SeasonNumber)
+				}
+				if len(row.Item.ProviderIDs) != 0 || !row.Watched || row.Favorite || row.Rating != nil {
+					t.Errorf("row = %+v", row)
+				}
+			},
+		},
+		{
+			name:   "short record",
+			record: ",Heat,TRUE",
+			check: func(t *testing.T, row csvRow) {
+				if !row.Watched || row.Favorite || row.Rating != nil {
+					t.Errorf("row = %+v", row)
+				}
+			},
+		},
+		{name: "empty name", record: "imdb:tt1,,yes,no,,,,", wantErr: "name is empty"},
+		{name: "episode without series", record: ",Pilot,yes,no,,episode,,", wantErr: "series_name is required for episodes"},
+		{name: "unknown type", record: ",Heat,yes,no,,show,,", wantErr: "unknown type: show"},
+		{name: "invalid season", record: ",Pilot,yes,no,,episode,Lost,one", wantErr: "invalid season_number: one"},
+		{name: "provider ID without provider", record: "tt0133093,The Matrix,yes,no,,,,", wantErr: "invalid provider_id, expected provider:id: tt0133093"},
+		{name: "provider ID without ID", record: "imdb:,The Matrix,yes,no,,,,", wantErr: "invalid provider_id, expected provider:id: imdb:"},
+		{name: "invalid watched", record: ",Heat,maybe,no,,,,", wantErr: "invalid watched: not a boolean: maybe"},
+		{name: "invalid favorite", record: ",Heat,yes,2,,,,", wantErr: "invalid favorite: not a boolean: 2"},
+		{name: "rating above range", record: ",Heat,yes,no,11,,,", wantErr: "invalid rating, expected 0 to 10: 11"},
+		{name: "negative rating", record: ",Heat,yes,no,-1,,,", wantErr: "invalid rating, expected 0 to 10: -1"},
+		{name: "rating not a number", record: ",Heat,yes,no,good,,,", wantErr: "invalid rating, expected 0 to 10: good"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			row, err := parseCSVRecord(strings.Split(test.record, ","), columns, 2)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("error = %v, want %s", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			test.check(t, row)
+		})
+	}
+}
+
+func TestParseCSVBool(t *testing.T) {
+	for _, value := range []string{"1", "true", "TRUE", "yes", "Y", "x"} {
+		if got, err := parseCSVBool(value); err != nil || !got {
+			t.Errorf("parseCSVBool(%q) = %v, %v, want true", value, got, err)
+		}
+	}
+	for _, value := range []string{"", "0", "false", "No", "n"} {
+		if got, err := parseCSVBool(value); err != nil || got {
+			t.Errorf("parseCSVBool(%q) = %v, %v, want false", value, got, err)
+		}
+	}
+	for _, value := range []string{"2", "maybe", "on"} {
+		if _, err := parseCSVBool(value); err == nil {
+			t.Errorf("parseCSVBool(%q) did not fail", value)
+		}
+	}
+}
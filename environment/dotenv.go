@@ -0,0 +1,64 @@
+package environment
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultEnvFile is loaded from the working directory if no other file is given
+const DefaultEnvFile = ".env"
+
+// LoadEnvFile reads KEY=VALUE pairs from a file and sets them as environment variables.
+// Blank lines and lines starting with # are ignored. Variables that are already set
+// in the environment are not overwritten. If the file does not exist and is not
+// required, nil is returned
+func LoadEnvFile(filename string, required bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return fmt.Errorf("%s line %d: expected KEY=VALUE", filename, lineNumber)
+		}
+		value = unquote(strings.TrimSpace(value))
+
+		if _, exists := os.LookupEnv(key); exists {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("%s line %d: %w", filename, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// unquote removes matching single or double quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
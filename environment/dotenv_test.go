@@ -0,0 +1,81 @@
+package environment
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// unsetEnv removes key from the environment for the duration of the test
+func unsetEnv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	content := `# Jellyfin server
+JFM_TEST_PLAIN=http://localhost:8096
+  JFM_TEST_SPACES  =  value with spaces
+
+export JFM_TEST_EXPORT=exported
+JFM_TEST_DOUBLE="double quoted # not a comment"
+JFM_TEST_SINGLE='single quoted'
+JFM_TEST_MISMATCHED="mismatched'
+JFM_TEST_EMPTY=
+JFM_TEST_EQUALS=a=b
+JFM_TEST_SET=from file
+	# indented comment
+`
+	want := map[string]string{
+		"JFM_TEST_PLAIN":      "http://localhost:8096",
+		"JFM_TEST_SPACES":     "value with spaces",
+		"JFM_TEST_EXPORT":     "exported",
+		"JFM_TEST_DOUBLE":     "double quoted # not a comment",
+		"JFM_TEST_SINGLE":     "single quoted",
+		"JFM_TEST_MISMATCHED": `"mismatched'`,
+		"JFM_TEST_EMPTY":      "",
+		"JFM_TEST_EQUALS":     "a=b",
+		"JFM_TEST_SET":        "from environment",
+	}
+	for key := range want {
+		unsetEnv(t, key)
+	}
+	t.Setenv("JFM_TEST_SET", "from environment")
+
+	filename := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadEnvFile(filename, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for key, value := range want {
+		got, exists := os.LookupEnv(key)
+		if !exists || got != value {
+			t.Errorf("%s = %q (set: %v), want %q", key, got, exists, value)
+		}
+	}
+}
+
+func TestLoadEnvFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.env")
+	if err := LoadEnvFile(missing, false); err != nil {
+		t.Errorf("missing optional file: unexpected error: %v", err)
+	}
+	if err := LoadEnvFile(missing, true); err == nil {
+		t.Error("missing required file: expected an error")
+	}
+
+	for _, line := range []string{"JFM_TEST_INVALID", "=value", "export"} {
+		filename := filepath.Join(dir, "invalid.env")
+		if err := os.WriteFile(filename, []byte("# comment\n"+line+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := LoadEnvFile(filename, true)
+		want := filename + " line 2: expected KEY=VALUE"
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: error = %v, want %s", line, err, want)
+		}
+	}
+}
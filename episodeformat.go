@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// defaultEpisodeFormat is used for missing episodes unless -episode-format is set
+const defaultEpisodeFormat = "S{season}E{episode}"
+
+// episodeFormatter formats season and episode numbers of a series with a template
+// containing {season} and {episode}
+type episodeFormatter struct {
+	template     string
+	seasonWidth  int
+	episodeWidth int
+}
+
+// newEpisodeFormatter pads the numbers to at least two digits, or more if the highest
+// season or episode number of the series needs it, so they stay aligned and sortable
+func newEpisodeFormatter(template string, episodes []models.MissingEpisode) episodeFormatter {
+	if template == "" {
+		template = defaultEpisodeFormat
+	}
+	formatter := episodeFormatter{template: template, seasonWidth: 2, episodeWidth: 2}
+	for _, ep := range episodes {
+		formatter.seasonWidth = max(formatter.seasonWidth, len(strconv.Itoa(ep.SeasonNumber)))
+		formatter.episodeWidth = max(formatter.episodeWidth, len(strconv.Itoa(ep.EpisodeNumber)))
+	}
+	return formatter
+}
+
+// format returns the formatted season and episode number, e.g. S01E05
+func (f episodeFormatter) format(season, episode int) string {
+	return strings.NewReplacer(
+		"{season}", fmt.Sprintf("%0*d", f.seasonWidth, season),
+		"{episode}", fmt.Sprintf("%0*d", f.episodeWidth, episode),
+	).Replace(f.template)
+}
+
+// validateEpisodeFormat checks that a template for -episode-format contains the episode number
+func validateEpisodeFormat(template string) error {
+	if !strings.Contains(template, "{episode}") {
+		return fmt.Errorf("episode format must contain {episode}: %s", template)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// parseEpisodeTemplate parses the -template for missing episodes. The fields are those of
+// models.MissingEpisode, e.g. {{.SeriesName}} {{.SeasonNumber}}x{{.EpisodeNumber}}
+func parseEpisodeTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("episode").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing -template: %w", err)
+	}
+	// Unknown fields are only reported on execution, so check them before the scan
+	if err := tmpl.Execute(io.Discard, models.MissingEpisode{}); err != nil {
+		return nil, fmt.Errorf("invalid -template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// printEpisodeTemplate prints a missing episode with the template on its own line
+func printEpisodeTemplate(tmpl *template.Template, episode models.MissingEpisode) {
+	var line strings.Builder
+	if err := tmpl.Execute(&line, episode); err != nil {
+		fmt.Printf("    ⚠ Could not apply -template: %v\n", err)
+		return
+	}
+	fmt.Println(strings.TrimRight(line.String(), "\n"))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// favoriteChanges collects the favorites of a restore that were found in the library.
+// They are marked as favorites after the watched state was restored
+type favoriteChanges struct {
+	// items have the ID of the matched library item
+	items []models.WatchedItem
+}
+
+// add records the matched library item if the restored item is a favorite
+func (f *favoriteChanges) add(item models.WatchedItem, info libraryItem) {
+	if !item.IsFavorite {
+		return
+	}
+	item.ID = info.ID
+	f.items = append(f.items, item)
+}
+
+// count returns the number of collected favorites, 0 if f is nil
+func (f *favoriteChanges) count() int {
+	if f == nil {
+		return 0
+	}
+	return len(f.items)
+}
+
+// collect returns options whose OnMatch also records the favorites in f
+func (f *favoriteChanges) collect(options restoreOptions) restoreOptions {
+	onMatch := options.OnMatch
+	options.OnMatch = func(item models.WatchedItem, info libraryItem) {
+		f.add(item, info)
+		if onMatch != nil {
+			onMatch(item, info)
+		}
+	}
+	return options
+}
+
+// apply marks the collected items as favorites and returns the number of items that failed
+func (f *favoriteChanges) apply(client *jellyfin.Client) int {
+	if f.count() == 0 {
+		return 0
+	}
+	fmt.Printf("\n=== Marking %d Items As Favorites ===\n", len(f.items))
+	marked, failed := 0, 0
+	for _, item := range f.items {
+		if restoreStopped(client) {
+			break
+		}
+		if err := client.SetFavorite(item.ID, true); err != nil {
+			fmt.Printf("  ✗ %s - failed to mark as favorite: %v\n", itemDisplayName(item), err)
+			failed++
+			if errors.Is(err, jellyfin.ErrWriteDenied) {
+				break
+			}
+			continue
+		}
+		marked++
+	}
+	fmt.Printf("Marked %d of %d items as favorites\n", marked, len(f.items))
+	recordCount("favorites", marked)
+	return len(f.items) - marked
+}
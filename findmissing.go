@@ -0,0 +1,642 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/api/tvdb"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// findMissingOptions holds the settings for finding missing episodes
+type findMissingOptions struct {
+	IncludeSpecials   bool
+	SkipMovieSpecials bool
+	// Seasons limits the check to these season numbers. All seasons are checked if empty
+	Seasons map[int]bool
+	// UnresolvedFile is the path series without a TVDB ID are written to. Not written if empty
+	UnresolvedFile string
+	// CheckpointFile is the path the progress is saved to. No progress is saved if empty
+	CheckpointFile string
+	// Resume continues from the progress saved in CheckpointFile
+	Resume bool
+	// ReportFile is the path the results are written to
+	ReportFile string
+	// ReportFormat is the format of ReportFile, see parseReportFormat
+	ReportFormat string
+	// Baseline is the path of a JSON report of an earlier run to compare the results with
+	Baseline string
+	// ShowOverviews prints the synopsis below each missing episode
+	ShowOverviews bool
+	// DefaultRuntime is the runtime in minutes assumed for TVDB episodes without one
+	// when detecting merged multi-part episodes
+	DefaultRuntime int
+	// TryAlternateOrder also matches missing episodes by their numbers in the other orders of a series
+	TryAlternateOrder bool
+	// Exclude skips series whose name matches one of the patterns
+	Exclude []seriesPattern
+	// Template formats each missing episode in the output instead of the default line, if set
+	Template *template.Template
+	// GroupBy is groupBySeries or groupByAirDate
+	GroupBy string
+	// DryRun only estimates the number of requests, without checking any series
+	DryRun bool
+	// ReportComplete also prints series without missing episodes
+	ReportComplete bool
+	// CrossSeasonMerge allows a multi-part file to span the end of one season and the start of the next
+	CrossSeasonMerge bool
+	// EpisodeFormat is the template for season and episode numbers, see episodeFormatter
+	EpisodeFormat string
+	// DetectSplitSeasons compares seasons split into parts on one side with the combined season on the other
+	DetectSplitSeasons bool
+	// ProgressInterval is how often the status line is updated, 0 disables it
+	ProgressInterval time.Duration
+	// SeriesMap replaces the names of Jellyfin series before they are matched with -exclude and
+	// reported, and limits the check of single series to some seasons
+	SeriesMap seriesMap
+	// SearchList is the path a search query for each missing episode is written to. Not written if empty
+	SearchList string
+	// SearchFormat is the template of the search queries, see missingReport.searchList
+	SearchFormat string
+}
+
+// Groupings of the missing episodes for -group-by
+const (
+	groupBySeries  = "series"
+	groupByAirDate = "airdate"
+)
+
+// seriesResult holds the outcome of checking a single series for missing episodes
+type seriesResult struct {
+	SeriesName    string                  `json:"series_name"`
+	TvdbID        string                  `json:"tvdb_id"`
+	TvdbURL       string                  `json:"tvdb_url,omitempty"`
+	TotalEpisodes int                     `json:"total_episodes"`
+	Missing       []models.MissingEpisode `json:"missing,omitempty"`
+	Warnings      []string                `json:"warnings,omitempty"`
+	Error         *models.SeriesError     `json:"error,omitempty"`
+}
+
+// parseSeasons parses a comma-separated list of season numbers
+func parseSeasons(value string) (map[int]bool, error) {
+	seasons := make(map[int]bool)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("not a season number: %s", field)
+		}
+		seasons[number] = true
+	}
+	return seasons, nil
+}
+
+func performFindMissing(ctx context.Context, jellyfinClient *jellyfin.Client, tvdbClient *tvdb.Client, options findMissingOptions) error {
+	// Read the baseline first, so a wrong file is noticed before the scan
+	var baseline missingReport
+	if options.Baseline != "" {
+		var err error
+		baseline, err = loadBaseline(options.Baseline)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The TVDB client is shared between all profiles, so it only needs to log in once
+	if !tvdbClient.LoggedIn() && !options.DryRun {
+		fmt.Println("Initializing TVDB client...")
+		if err := tvdbClient.Login(); err != nil {
+			return fmt.Errorf("TVDB login failed: %w", err)
+		}
+		fmt.Println("✓ TVDB authentication successful")
+	}
+
+	fmt.Println("\nFetching all series from Jellyfin...")
+	series, err := jellyfinClient.GetAllSeries()
+	if err != nil {
+		return fmt.Errorf("fetching Jellyfin series: %w", err)
+	}
+	fmt.Printf("✓ Found %d series in Jellyfin\n", len(series))
+	// The seasons and runtimes are looked up by the Jellyfin name, before the series are renamed
+	seriesSeasons := make(map[string]map[int]bool)
+	seriesRuntimes := make(map[string]int)
+	for _, s := range series {
+		if seasons := options.SeriesMap.seasons(s.Name); seasons != nil {
+			seriesSeasons[s.ID] = seasons
+		}
+		if runtime, found := options.SeriesMap.defaultRuntime(s.Name); found {
+			seriesRuntimes[s.ID] = runtime
+		}
+	}
+	if len(options.SeriesMap) != 0 {
+		fmt.Printf("Renamed %d series with -rename-map\n", renameSeries(series, options.SeriesMap))
+	}
+
+	checkpoint := newCheckpoint(series)
+	if options.Resume {
+		checkpoint = resumeCheckpoint(options.CheckpointFile, series)
+	}
+
+	// Excluded series are removed after creating the checkpoint, so changing
+	// the patterns does not discard the saved progress
+	if len(options.Exclude) != 0 {
+		var excluded int
+		series, excluded = excludeSeries(series, options.Exclude)
+		fmt.Printf("Excluded %d series matching -exclude\n", excluded)
+	}
+
+	if options.DryRun {
+		tvdbCalls, jellyfinCalls := estimateFindMissingCalls(series, checkpoint, options, tvdbClient)
+		fmt.Printf("\nDry run: estimated ~%d TVDB calls, ~%d Jellyfin calls\n", tvdbCalls, jellyfinCalls)
+		fmt.Printf("Series with more than %d episodes on TVDB need one more TVDB call per %d episodes\n", tvdbEpisodesPerPage, tvdbEpisodesPerPage)
+		return nil
+	}
+
+	// Stop after the current series on Ctrl-C or when the deadline has passed,
+	// so the results so far are not lost
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("Checking for missing episodes...")
+	report := missingReport{
+		CreatedAt:     time.Now(),
+		SeriesTotal:   len(series),
+		episodeFormat: options.EpisodeFormat,
+	}
+	var unresolved []jellyfin.SeriesInfo
+	processed := 0
+
+	// The status line is updated by another goroutine while the series are checked
+	var processedCount, missingCount atomic.Int64
+	status := startLiveStatus(options.ProgressInterval, func() string {
+		return fmt.Sprintf("Processed %d/%d series, %d missing so far", processedCount.Load(), len(series), missingCount.Load())
+	})
+
+	for i, s := range series {
+		if ctx.Err() != nil {
+			report.Partial = true
+			break
+		}
+		processed++
+
+		// Check if series has TVDB ID
+		tvdbID, hasTVDB := models.GetProviderID(s.ProviderIDs, models.ProviderTvdb)
+		if !hasTVDB {
+			unresolved = append(unresolved, s)
+			processedCount.Add(1)
+			continue
+		}
+
+		result, done := checkpoint.Results[s.ID]
+		if !done {
+			seriesOptions := options
+			// Seasons of the series map take precedence over -seasons
+			if seasons, found := seriesSeasons[s.ID]; found {
+				seriesOptions.Seasons = seasons
+			}
+			// Likewise for the default runtime and -default-runtime
+			if runtime, found := seriesRuntimes[s.ID]; found {
+				seriesOptions.DefaultRuntime = runtime
+			}
+			result = checkSeries(jellyfinClient, tvdbClient, s, tvdbID, seriesOptions)
+			status.clear()
+			// The requests of this series were cancelled, so it is checked again when resuming
+			if result.Error != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				report.Partial = true
+				processed--
+				break
+			}
+			if options.CheckpointFile != "" {
+				checkpoint.Results[s.ID] = result
+				if err := checkpoint.save(options.CheckpointFile); err != nil {
+					fmt.Printf("⚠ Could not save progress: %v\n", err)
+				}
+			}
+		}
+
+		printSeriesResult(i+1, len(series), result, options)
+		report.add(result)
+		processedCount.Add(1)
+		missingCount.Store(int64(report.TotalMissing))
+	}
+	status.stop()
+	report.SeriesChecked = processed - len(report.Errors) - len(unresolved)
+	report.sortBySeriesName()
+
+	if options.GroupBy == groupByAirDate {
+		printByAirDate(report, options)
+	}
+
+	if len(report.Errors) != 0 {
+		fmt.Printf("\n=== Series skipped due to errors ===\n")
+		for _, seriesError := range report.Errors {
+			fmt.Printf("  - %s (TVDB: %s): %s\n", seriesError.SeriesName, seriesError.TvdbID, seriesError.Reason)
+		}
+	}
+
+	fmt.Printf("\n=== Summary ===\n")
+	if report.Partial {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			fmt.Printf("⚠ Deadline reached, only %d of %d series were processed\n", processed, len(series))
+		} else {
+			fmt.Printf("⚠ Interrupted, only %d of %d series were processed\n", processed, len(series))
+		}
+	}
+	recordCount("series_total", len(series))
+	recordCount("series_checked", report.SeriesChecked)
+	recordCount("series_errors", len(report.Errors))
+	recordCount("series_without_tvdb_id", len(unresolved))
+	recordCount("missing_episodes", report.TotalMissing)
+	fmt.Printf("Total series checked: %d\n", report.SeriesChecked)
+	fmt.Printf("Series skipped due to errors: %d\n", len(report.Errors))
+	fmt.Printf("Total missing episodes: %d\n", report.TotalMissing)
+	if report.Duplicates > 0 {
+		fmt.Printf("Duplicates left out (series in several libraries): %d\n", report.Duplicates)
+	}
+
+	if options.Baseline != "" {
+		printBaselineDiff(baseline, diffWithBaseline(baseline, report), options.EpisodeFormat)
+	}
+
+	if options.ReportFile != "" {
+		if err := writeReport(options.ReportFile, options.ReportFormat, report); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		fmt.Printf("✓ Wrote report to %s\n", options.ReportFile)
+	}
+
+	if options.SearchList != "" {
+		if err := writeSearchList(options.SearchList, options.SearchFormat, report); err != nil {
+			return fmt.Errorf("writing search list: %w", err)
+		}
+		fmt.Printf("✓ Wrote %d search queries to %s\n", report.TotalMissing, options.SearchList)
+	}
+
+	if options.UnresolvedFile != "" {
+		if err := writeUnresolvedSeries(options.UnresolvedFile, unresolved); err != nil {
+			return fmt.Errorf("writing unresolved series: %w", err)
+		}
+		fmt.Printf("✓ Wrote %d series without TVDB ID to %s\n", len(unresolved), options.UnresolvedFile)
+	}
+
+	if report.Partial {
+		return fmt.Errorf("interrupted after %d of %d series", processed, len(series))
+	}
+
+	if options.CheckpointFile != "" {
+		if err := os.Remove(options.CheckpointFile); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("⚠ Could not remove progress file: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// tvdbEpisodesPerPage is the number of episodes TVDB returns per request
+const tvdbEpisodesPerPage = 500
+
+// estimateFindMissingCalls estimates the number of requests a find-missing run makes, assuming
+// every series fits on one page of TVDB episodes. Requests that depend on the result, e.g. the
+// series page link for series with missing episodes, are counted as if they were always made
+func estimateFindMissingCalls(series []jellyfin.SeriesInfo, checkpoint *findMissingCheckpoint, options findMissingOptions, tvdbClient *tvdb.Client) (tvdbCalls, jellyfinCalls int) {
+	// The list of series has already been fetched
+	jellyfinCalls = 1
+	if !tvdbClient.LoggedIn() {
+		tvdbCalls++
+	}
+	for _, s := range series {
+		if _, hasTVDB := models.GetProviderID(s.ProviderIDs, models.ProviderTvdb); !hasTVDB {
+			continue
+		}
+		if _, done := checkpoint.Results[s.ID]; done {
+			continue
+		}
+		jellyfinCalls++
+		// Episodes, and the extended series for the link, seasons and alternate orders
+		tvdbCalls += 2
+		if tvdbClient.Language() != "" {
+			tvdbCalls++
+		}
+		if options.TryAlternateOrder {
+			tvdbCalls++
+		}
+	}
+	return tvdbCalls, jellyfinCalls
+}
+
+// checkSeries compares the episodes of a series in Jellyfin with TVDB
+func checkSeries(jellyfinClient *jellyfin.Client, tvdbClient *tvdb.Client, s jellyfin.SeriesInfo, tvdbID string, options findMissingOptions) seriesResult {
+	result := seriesResult{
+		SeriesName: s.Name,
+		TvdbID:     tvdbID,
+	}
+
+	// Get episodes from TVDB
+	tvdbEpisodes, err := tvdbClient.GetSeriesEpisodes(tvdbID)
+	if err != nil {
+		result.Error = &models.SeriesError{
+			SeriesName: s.Name,
+			TvdbID:     tvdbID,
+			Reason:     fmt.Sprintf("fetching TVDB episodes: %v", err),
+		}
+		return result
+	}
+	// All episodes are kept to check if TVDB returned all seasons, see below
+	allTvdbEpisodes := tvdbEpisodes
+
+	// Remove movies, so they are not reported as missing
+	if options.SkipMovieSpecials {
+		seasons, err := tvdbClient.GetSeasonsForSeries(tvdbID)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Could not fetch TVDB seasons, only using episode flags: %v", err))
+		}
+		tvdbEpisodes = tvdb.FilterMovieSpecials(tvdbEpisodes, seasons)
+	}
+	// The seasons are detected before -seasons is applied, so the numbering of all seasons is known
+	var tvdbNumbers [][2]int
+	if options.DetectSplitSeasons {
+		for _, ep := range tvdbEpisodes {
+			tvdbNumbers = append(tvdbNumbers, [2]int{ep.SeasonNumber, ep.Number})
+		}
+	}
+	if len(options.Seasons) != 0 {
+		tvdbEpisodes = tvdb.FilterSeasons(tvdbEpisodes, options.Seasons)
+	}
+	result.TotalEpisodes = len(tvdbEpisodes)
+
+	// Get episodes from Jellyfin
+	jellyfinEpisodes, err := jellyfinClient.GetEpisodesForSeries(s.ID)
+	if err != nil {
+		result.Error = &models.SeriesError{
+			SeriesName: s.Name,
+			TvdbID:     tvdbID,
+			Reason:     fmt.Sprintf("fetching Jellyfin episodes: %v", err),
+		}
+		return result
+	}
+	// Episodes with broken numbers cannot be compared and would show up as existing under a wrong number
+	jellyfinEpisodes = skipInvalidEpisodes(jellyfinEpisodes, &result)
+
+	// Compare seasons that are split into parts on one side with the combined season on the other
+	splitSeasons := make(splitSeasonMap)
+	if options.DetectSplitSeasons {
+		var jellyfinNumbers [][2]int
+		for _, ep := range jellyfinEpisodes {
+			if !ep.Virtual {
+				jellyfinNumbers = append(jellyfinNumbers, [2]int{ep.SeasonNumber, ep.EpisodeNumber})
+			}
+		}
+		var notes []string
+		splitSeasons, notes = detectSplitSeasons(seasonSpans(jellyfinNumbers), seasonSpans(tvdbNumbers))
+		result.Warnings = append(result.Warnings, notes...)
+	}
+
+	// Build map of existing episodes and store runtime seconds
+	// The runtime is required to check if two multi-part episodes have been merged
+	existingEpisodes := make(map[string]int)
+	specialNames := make(map[string]bool)
+	for _, ep := range jellyfinEpisodes {
+		// Placeholders for missing episodes have no file, so they do not count as existing
+		if ep.Virtual {
+			continue
+		}
+		season := splitSeasons.season(ep.SeasonNumber, ep.EpisodeNumber)
+		if len(options.Seasons) != 0 && !options.Seasons[season] {
+			continue
+		}
+		key := fmt.Sprintf("%d:%d", season, ep.EpisodeNumber)
+		existingEpisodes[key] = ep.RuntimeMinutes
+		if ep.SeasonNumber == 0 {
+			specialNames[normalizeTitle(ep.Name)] = true
+		}
+	}
+
+	// Find missing episodes
+	result.Missing = tvdb.FindMissingEpisodes(tvdbEpisodes, existingEpisodes, options.IncludeSpecials, options.DefaultRuntime, options.CrossSeasonMerge)
+	result.Missing = removeSpecialsFoundByName(result.Missing, specialNames)
+	if options.TryAlternateOrder && len(result.Missing) != 0 {
+		result.Missing, err = removeFoundInAlternateOrder(tvdbClient, tvdbID, result.Missing, existingEpisodes)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("Could not check alternate episode orders: %v", err))
+		}
+	}
+
+	// Link to the series page, so the missing episodes can be investigated
+	if len(result.Missing) != 0 {
+		seriesExtended, err := tvdbClient.SearchSeriesByTVDBID(tvdbID)
+		if err == nil && seriesExtended.Slug != "" {
+			result.TvdbURL = tvdb.SeriesURL(seriesExtended.Slug)
+		}
+		// Incomplete data from TVDB would report whole seasons as missing, so the series is skipped instead
+		if err == nil {
+			if empty := tvdb.EmptySeasons(seriesExtended.Seasons, allTvdbEpisodes); len(empty) != 0 {
+				result.Missing = nil
+				result.Error = &models.SeriesError{
+					SeriesName: s.Name,
+					TvdbID:     tvdbID,
+					Reason:     fmt.Sprintf("TVDB returned no episodes for season %s, the episode data may be incomplete", joinInts(empty)),
+				}
+			}
+		}
+	}
+	return result
+}
+
+// skipInvalidEpisodes removes episodes with out of range numbers and adds a warning to the result
+func skipInvalidEpisodes(episodes []jellyfin.EpisodeInfo, result *seriesResult) []jellyfin.EpisodeInfo {
+	valid := make([]jellyfin.EpisodeInfo, 0, len(episodes))
+	var skipped []string
+	for _, ep := range episodes {
+		if ep.InvalidNumbers {
+			skipped = append(skipped, fmt.Sprintf("%q (season %d, episode %d)", ep.Name, ep.SeasonNumber, ep.EpisodeNumber))
+			continue
+		}
+		valid = append(valid, ep)
+	}
+	if len(skipped) != 0 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Skipped %d Jellyfin episodes with invalid numbers: %s", len(skipped), strings.Join(skipped, ", ")))
+	}
+	return valid
+}
+
+// joinInts returns the numbers separated by commas
+func joinInts(numbers []int) string {
+	parts := make([]string, len(numbers))
+	for i, number := range numbers {
+		parts[i] = strconv.Itoa(number)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// removeFoundInAlternateOrder removes missing episodes that exist in Jellyfin under their
+// number in another order of the series, e.g. if the library is sorted by DVD order.
+// Episodes are identified by their TVDB ID, which is the same in all orders
+func removeFoundInAlternateOrder(tvdbClient *tvdb.Client, tvdbID string, missing []models.MissingEpisode, existingEpisodes map[string]int) ([]models.MissingEpisode, error) {
+	seasons, err := tvdbClient.GetSeasonsForSeries(tvdbID)
+	if err != nil {
+		return missing, err
+	}
+	found := make(map[int]bool)
+	for _, seasonType := range tvdb.AlternateSeasonTypes(seasons) {
+		episodes, err := tvdbClient.GetSeriesEpisodesInOrder(tvdbID, seasonType)
+		if err != nil {
+			return missing, fmt.Errorf("fetching %s order: %w", seasonType, err)
+		}
+		for _, ep := range episodes {
+			key := fmt.Sprintf("%d:%d", ep.SeasonNumber, ep.Number)
+			if _, exists := existingEpisodes[key]; exists {
+				found[ep.ID] = true
+			}
+		}
+	}
+	if len(found) == 0 {
+		return missing, nil
+	}
+
+	filtered := make([]models.MissingEpisode, 0, len(missing))
+	for _, m := range missing {
+		if m.TvdbID != 0 && found[m.TvdbID] {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered, nil
+}
+
+// removeSpecialsFoundByName removes specials whose name matches a special in Jellyfin.
+// Specials are numbered inconsistently between sources, so a special stored under a
+// different number should not be reported as missing
+func removeSpecialsFoundByName(missing []models.MissingEpisode, specialNames map[string]bool) []models.MissingEpisode {
+	if len(specialNames) == 0 {
+		return missing
+	}
+	filtered := make([]models.MissingEpisode, 0, len(missing))
+	for _, m := range missing {
+		name := normalizeTitle(m.EpisodeName)
+		if m.SeasonNumber == 0 && name != "" && specialNames[name] {
+			continue
+		}
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
+
+// printSeriesResult prints warnings, errors and missing episodes of a series.
+// Nothing is printed for complete series unless ReportComplete is set
+func printSeriesResult(index, total int, result seriesResult, options findMissingOptions) {
+	missing := result.Missing
+	if options.GroupBy == groupByAirDate {
+		// Listed for all series together after the scan
+		missing = nil
+	}
+	if len(result.Warnings) == 0 && result.Error == nil && len(missing) == 0 && !options.ReportComplete {
+		return
+	}
+
+	fmt.Printf("\n[%d/%d] %s (TVDB: %s)\n", index, total, result.SeriesName, result.TvdbID)
+	for _, warning := range result.Warnings {
+		fmt.Printf("  ⚠ %s\n", warning)
+	}
+	if result.Error != nil {
+		fmt.Printf("  ⚠ Could not check series: %s\n", result.Error.Reason)
+		return
+	}
+	if len(missing) != 0 {
+		if result.TvdbURL != "" {
+			fmt.Printf("  %s\n", result.TvdbURL)
+		}
+		fmt.Printf("  ⚠ Missing %d episodes (of %d total):\n", len(missing), result.TotalEpisodes)
+		formatter := newEpisodeFormatter(options.EpisodeFormat, missing)
+		for _, m := range missing {
+			if options.Template != nil {
+				m.SeriesName = result.SeriesName
+				printEpisodeTemplate(options.Template, m)
+			} else {
+				fmt.Printf("    - %s: %s (Aired: %s)\n",
+					formatter.format(m.SeasonNumber, m.EpisodeNumber), m.EpisodeName, m.AirDate)
+			}
+			if options.ShowOverviews && m.Overview != "" {
+				printOverview(m.Overview)
+			}
+		}
+	} else if options.ReportComplete {
+		if len(result.Missing) == 0 {
+			fmt.Printf("  ✓ complete (%d episodes)\n", result.TotalEpisodes)
+		} else {
+			fmt.Printf("  ⚠ Missing %d episodes (of %d total)\n", len(result.Missing), result.TotalEpisodes)
+		}
+	}
+}
+
+// printByAirDate prints the missing episodes of all series, the most recently aired first
+func printByAirDate(report missingReport, options findMissingOptions) {
+	episodes := report.byAirDate()
+	if len(episodes) == 0 {
+		return
+	}
+	fmt.Printf("\n=== Missing Episodes By Air Date ===\n")
+	formatter := newEpisodeFormatter(options.EpisodeFormat, episodes)
+	for _, m := range episodes {
+		if options.Template != nil {
+			printEpisodeTemplate(options.Template, m)
+			continue
+		}
+		airDate := m.AirDate
+		if airDate == "" {
+			airDate = "unknown"
+		}
+		fmt.Printf("  %-10s  %s - %s: %s\n", airDate, m.SeriesName, formatter.format(m.SeasonNumber, m.EpisodeNumber), m.EpisodeName)
+		if options.ShowOverviews && m.Overview != "" {
+			printOverview(m.Overview)
+		}
+	}
+}
+
+// overviewIndent is the indentation of overviews below the missing episode lines
+const overviewIndent = "        "
+
+// printOverview prints the synopsis of an episode, wrapped to the terminal width
+func printOverview(overview string) {
+	width := terminalWidth() - len(overviewIndent)
+	if width < 20 {
+		width = 20
+	}
+	for _, line := range wrapText(overview, width) {
+		fmt.Printf("%s%s\n", overviewIndent, line)
+	}
+}
+
+// writeUnresolvedSeries writes the names and available provider IDs of series without a TVDB ID to a file
+func writeUnresolvedSeries(filename string, series []jellyfin.SeriesInfo) error {
+	var builder strings.Builder
+	for _, s := range series {
+		providers := make([]string, 0, len(s.ProviderIDs))
+		for provider, id := range s.ProviderIDs {
+			if id != "" {
+				providers = append(providers, provider+"="+id)
+			}
+		}
+		sort.Strings(providers)
+
+		if len(providers) == 0 {
+			fmt.Fprintf(&builder, "%s (no provider IDs)\n", s.Name)
+		} else {
+			fmt.Fprintf(&builder, "%s (%s)\n", s.Name, strings.Join(providers, ", "))
+		}
+	}
+	return os.WriteFile(filename, []byte(builder.String()), 0644)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// icsMaxLineLength is the maximum length of a line in an iCalendar file in bytes, see RFC 5545 3.1
+const icsMaxLineLength = 75
+
+// icsDate is the format of the DATE values of all-day events
+const icsDate = "20060102"
+
+// icsTextEscaper escapes TEXT values, see RFC 5545 3.3.11
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// performExportICS writes an iCalendar file with an all-day event for every watched item on the
+// day it was played. Items without a played date are left out
+func performExportICS(client *jellyfin.Client, filename string) error {
+	fmt.Printf("Fetching watched items for %s...\n", client.GetConfig().UserName)
+	items, err := client.GetWatchedItems()
+	if err != nil {
+		return fmt.Errorf("fetching watched items: %w", err)
+	}
+
+	calendar, events := buildICS(items, time.Now())
+	if err := os.WriteFile(filename, []byte(calendar), 0644); err != nil {
+		return fmt.Errorf("writing calendar: %w", err)
+	}
+	fmt.Printf("✓ Wrote %d watch dates to %s\n", events, filename)
+	recordCount("events", events)
+	recordCount("without_played_date", len(items)-events)
+	if skipped := len(items) - events; skipped > 0 {
+		fmt.Printf("○ %d watched items have no played date and were left out\n", skipped)
+	}
+	return nil
+}
+
+// buildICS returns the calendar and the number of events in it. Dates are in the local time zone
+func buildICS(items []models.WatchedItem, now time.Time) (string, int) {
+	var builder strings.Builder
+	writeLine := func(line string) {
+		builder.WriteString(foldICSLine(line))
+		builder.WriteString("\r\n")
+	}
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//JellyfinManager//" + appVersion + "//EN")
+	writeLine("CALSCALE:GREGORIAN")
+	writeLine("X-WR-CALNAME:Watch history")
+	events := 0
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, item := range items {
+		if item.PlayedDate.IsZero() {
+			continue
+		}
+		day := item.PlayedDate.Local()
+		writeLine("BEGIN:VEVENT")
+		writeLine("UID:" + item.ID + "-" + day.Format(icsDate) + "@jellyfinmanager")
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("DTSTART;VALUE=DATE:" + day.Format(icsDate))
+		writeLine("DTEND;VALUE=DATE:" + day.AddDate(0, 0, 1).Format(icsDate))
+		writeLine("SUMMARY:" + icsTextEscaper.Replace("Watched: "+icsTitle(item)))
+		writeLine("TRANSP:TRANSPARENT")
+		writeLine("END:VEVENT")
+		events++
+	}
+	writeLine("END:VCALENDAR")
+	return builder.String(), events
+}
+
+// icsTitle returns the name of a movie, or the series, season and episode of an episode
+func icsTitle(item models.WatchedItem) string {
+	if item.Type != models.TypeEpisode {
+		return item.Name
+	}
+	var episode string
+	switch {
+	case item.SeasonNumber != nil && item.EpisodeNumber != nil:
+		episode = fmt.Sprintf("S%02dE%02d", *item.SeasonNumber, *item.EpisodeNumber)
+	case item.SeasonName != "":
+		episode = item.SeasonName
+	}
+	if episode == "" {
+		return item.SeriesName + " - " + item.Name
+	}
+	return item.SeriesName + " - " + episode + " - " + item.Name
+}
+
+// foldICSLine splits lines longer than icsMaxLineLength bytes. Continuation lines start with
+// a space and multi-byte characters are not split
+func foldICSLine(line string) string {
+	if len(line) <= icsMaxLineLength {
+		return line
+	}
+	var builder strings.Builder
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > icsMaxLineLength {
+			builder.WriteString("\r\n ")
+			// The leading space counts towards the length of the continuation line
+			length = 1
+		}
+		builder.WriteRune(r)
+		length += size
+	}
+	return builder.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+)
+
+// keyringService is the service name the API keys are stored under in the system keyring
+const keyringService = "jellyfinmanager"
+
+// tvdbKeyringAccount is the account of the TVDB API key in the system keyring
+const tvdbKeyringAccount = "tvdb"
+
+// errKeyringNotFound is returned by keyringGet if no key is stored for the account
+var errKeyringNotFound = errors.New("not found in the system keyring")
+
+// jellyfinKeyringAccount returns the account of the API key of a Jellyfin server in the system keyring
+func jellyfinKeyringAccount(serverURL string) string {
+	if normalized, err := jellyfin.NormalizeServerURL(serverURL); err == nil {
+		serverURL = normalized
+	}
+	return "jellyfin:" + serverURL
+}
+
+// keyringCredential returns the key for the account. If no key was provided, it is read
+// from the system keyring. Otherwise the user is asked to store it, unless it is already stored
+func keyringCredential(account, provided, description string) string {
+	stored, err := keyringGet(account)
+	if err != nil && !errors.Is(err, errKeyringNotFound) {
+		fmt.Printf("⚠ Could not read the %s from the system keyring: %v\n", description, err)
+		return provided
+	}
+
+	if provided == "" {
+		if stored != "" {
+			fmt.Printf("✓ Using the %s from the system keyring\n", description)
+		}
+		return stored
+	}
+	if stored == provided {
+		return provided
+	}
+	if confirm(fmt.Sprintf("Store the %s in the system keyring?", description)) {
+		if err := keyringSet(account, provided); err != nil {
+			fmt.Printf("⚠ Could not store the %s in the system keyring: %v\n", description, err)
+		} else {
+			fmt.Printf("✓ Stored the %s in the system keyring\n", description)
+		}
+	}
+	return provided
+}
+
+// keyringCommandError describes a failed keyring command with its error output
+func keyringCommandError(command string, err error, stderr string) error {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return fmt.Errorf("running %s: %w", command, err)
+	}
+	return fmt.Errorf("running %s: %w: %s", command, err, stderr)
+}
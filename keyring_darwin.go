@@ -0,0 +1,41 @@
+//go:build darwin
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keyringGet reads a key from the macOS Keychain
+func keyringGet(account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// Exit code 44 means that the item could not be found
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", errKeyringNotFound
+		}
+		return "", keyringCommandError("security", err, stderr.String())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// keyringSet stores a key in the macOS Keychain. The command is passed on stdin,
+// so the key does not show up in the process list
+func keyringSet(account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n", keyringService, account, secret))
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return keyringCommandError("security", err, stderr.String())
+	}
+	return nil
+}
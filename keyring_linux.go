@@ -0,0 +1,42 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"strings"
+)
+
+// keyringGet reads a key from the Secret Service, e.g. GNOME Keyring or KWallet,
+// with secret-tool from libsecret
+func keyringGet(account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		// secret-tool exits with 1 and prints nothing if there is no matching item
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			return "", errKeyringNotFound
+		}
+		return "", keyringCommandError("secret-tool", err, stderr.String())
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// keyringSet stores a key in the Secret Service. The key is passed on stdin,
+// so it does not show up in the process list
+func keyringSet(account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store", "--label", "JellyfinManager "+account,
+		"service", keyringService, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return keyringCommandError("secret-tool", err, stderr.String())
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+// errKeyringUnsupported is returned on platforms without a supported keyring
+var errKeyringUnsupported = errors.New("the system keyring is not supported on this platform")
+
+// keyringGet is not supported on this platform
+func keyringGet(account string) (string, error) {
+	return "", errKeyringUnsupported
+}
+
+// keyringSet is not supported on this platform
+func keyringSet(account, secret string) error {
+	return errKeyringUnsupported
+}
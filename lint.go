@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// maxLintExamples is the number of affected items that are listed for each issue
+const maxLintExamples = 5
+
+// lintIssue is a problem that affects one or more items of a backup
+type lintIssue struct {
+	// Fatal issues prevent items from being restored, the others only make matching less reliable
+	Fatal   bool
+	Message string
+	Items   []string
+}
+
+// backupLinter collects the issues of a backup, in the order they were first found
+type backupLinter struct {
+	issues []*lintIssue
+	byText map[string]*lintIssue
+}
+
+// add records that item is affected by the issue with the given message
+func (l *backupLinter) add(fatal bool, message, item string) {
+	if l.byText == nil {
+		l.byText = make(map[string]*lintIssue)
+	}
+	issue, exists := l.byText[message]
+	if !exists {
+		issue = &lintIssue{Fatal: fatal, Message: message}
+		l.byText[message] = issue
+		l.issues = append(l.issues, issue)
+	}
+	issue.Items = append(issue.Items, item)
+}
+
+// performLint checks a backup file for problems without contacting a server.
+// It returns an error if items were found that cannot be restored
+func performLint(filename string) error {
+	data, err := readBackupFile(filename)
+	if err != nil {
+		return fmt.Errorf("reading backup file: %w", err)
+	}
+
+	var linter backupLinter
+	backup, err := parseBackupForLint(data, &linter)
+	if err != nil {
+		return fmt.Errorf("unmarshaling backup: %w", err)
+	}
+	lintBackup(backup, &linter)
+
+	var movies, episodes int
+	for _, item := range backup.WatchedItems {
+		switch item.Type {
+		case models.TypeMovie:
+			movies++
+		case models.TypeEpisode:
+			episodes++
+		}
+	}
+	fmt.Printf("=== Checking %s ===\n", filename)
+	fmt.Printf("Items: %d (%d movies, %d episodes)\n", len(backup.WatchedItems), movies, episodes)
+
+	if len(linter.issues) == 0 {
+		fmt.Println("✓ No issues found")
+		return nil
+	}
+	fatal := 0
+	for _, issue := range linter.issues {
+		symbol := "⚠"
+		if issue.Fatal {
+			symbol = "✗"
+			fatal += len(issue.Items)
+		}
+		// Issues of the backup itself have no items
+		if len(issue.Items) == 1 && issue.Items[0] == "" {
+			fmt.Printf("%s %s\n", symbol, issue.Message)
+			continue
+		}
+		fmt.Printf("%s %s: %d\n", symbol, issue.Message, len(issue.Items))
+		for _, item := range issue.Items[:min(len(issue.Items), maxLintExamples)] {
+			fmt.Printf("    - %s\n", item)
+		}
+		if len(issue.Items) > maxLintExamples {
+			fmt.Printf("    ... and %d more\n", len(issue.Items)-maxLintExamples)
+		}
+	}
+	if fatal > 0 {
+		return fmt.Errorf("%d items cannot be restored", fatal)
+	}
+	return nil
+}
+
+// parseBackupForLint parses a backup. Unlike loadBackup, JSON items that cannot be parsed,
+// e.g. because of an invalid date, are recorded as issues and skipped instead of failing
+func parseBackupForLint(data []byte, linter *backupLinter) (models.Backup, error) {
+	var backup models.Backup
+	err := unmarshalBackup(data, &backup)
+	if err == nil || bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return backup, err
+	}
+
+	// Parse the items one by one to find the broken ones
+	var raw struct {
+		models.Backup
+		WatchedItems []json.RawMessage `json:"watched_items"`
+	}
+	if json.Unmarshal(data, &raw) != nil {
+		return backup, err
+	}
+	backup = raw.Backup
+	backup.WatchedItems = nil
+	for i, itemData := range raw.WatchedItems {
+		var item models.WatchedItem
+		if err := json.Unmarshal(itemData, &item); err != nil {
+			linter.add(true, "Items that cannot be parsed", fmt.Sprintf("item %d: %v", i+1, err))
+			continue
+		}
+		backup.WatchedItems = append(backup.WatchedItems, item)
+	}
+	return backup, nil
+}
+
+// lintBackup records the issues of the backup and its items
+func lintBackup(backup models.Backup, linter *backupLinter) {
+	if backup.CreatedAt.IsZero() {
+		linter.add(false, "Backup has no creation date", "")
+	}
+	if backup.Checksum == "" {
+		linter.add(false, "Backup has no checksum", "")
+	} else if checksum, err := backup.CalculateChecksum(); err == nil && checksum != backup.Checksum {
+		linter.add(false, "Checksum mismatch, the file has been modified or is incomplete", "")
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range backup.WatchedItems {
+		name := itemDisplayName(item)
+		switch item.Type {
+		case models.TypeMovie:
+		case models.TypeEpisode:
+			if item.SeriesName == "" {
+				linter.add(true, "Episodes without series name", item.Name)
+			}
+		default:
+			linter.add(true, "Items with unknown type", fmt.Sprintf("%s (type %d)", name, item.Type))
+		}
+		if item.Name == "" {
+			linter.add(true, "Items without name", "ID "+item.ID)
+		}
+		if seen[item.ID] {
+			linter.add(false, "Duplicate items", fmt.Sprintf("%s (ID %s)", name, item.ID))
+		}
+		seen[item.ID] = true
+		if len(item.ProviderIDs) == 0 {
+			linter.add(false, "Items without provider IDs, only matched by name", name)
+		}
+		if item.PlayedDate.IsZero() {
+			linter.add(false, "Items without played date", name)
+		}
+	}
+}
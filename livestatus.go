@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// clearLine moves the cursor to the start of the line and erases it
+const clearLine = "\r\033[K"
+
+// liveStatus periodically shows a status line in the terminal that is overwritten in place.
+// It is written to the console only, so it never ends up in piped output or the -log-file
+type liveStatus struct {
+	mu      sync.Mutex
+	shown   bool
+	stopped bool
+	done    chan struct{}
+}
+
+// startLiveStatus shows the text returned by status every interval. It returns nil, which
+// is safe to use, if the interval is 0 or stdout is not a terminal
+func startLiveStatus(interval time.Duration, status func() string) *liveStatus {
+	if interval <= 0 || stdoutWidth() == 0 {
+		return nil
+	}
+	l := &liveStatus{done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+				l.show(status())
+			}
+		}
+	}()
+	return l
+}
+
+// show replaces the status line with text, shortened to the width of the terminal
+func (l *liveStatus) show(text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return
+	}
+	if runes := []rune(text); len(runes) >= terminalWidth() {
+		text = string(runes[:terminalWidth()-1])
+	}
+	fmt.Fprint(console, clearLine+text)
+	l.shown = true
+}
+
+// clear removes the status line, so regular output can be printed.
+// It is shown again on the next tick
+func (l *liveStatus) clear() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.shown {
+		fmt.Fprint(console, clearLine)
+		l.shown = false
+	}
+}
+
+// stop removes the status line and stops updating it
+func (l *liveStatus) stop() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopped {
+		return
+	}
+	l.stopped = true
+	close(l.done)
+	if l.shown {
+		fmt.Fprint(console, clearLine)
+		l.shown = false
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// rotatingLog appends to a log file and prefixes every line with the time. Once the file has
+// reached maxSize, it is renamed to path.1, path.1 to path.2 and so on. Only the newest keep rotated files are kept
+type rotatingLog struct {
+	path      string
+	maxSize   int64
+	keep      int
+	file      *os.File
+	size      int64
+	lineStart bool
+}
+
+// openRotatingLog opens the log file for appending and rotates it first if it is already full
+func openRotatingLog(path string, maxSize int64, keep int) (*rotatingLog, error) {
+	l := &rotatingLog{path: path, maxSize: maxSize, keep: keep, lineStart: true}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	if l.size >= l.maxSize {
+		if err := l.rotate(); err != nil {
+			l.file.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// open opens the log file and reads its current size
+func (l *rotatingLog) open() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("reading log file size: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the full log file and its older copies and starts a new file
+func (l *rotatingLog) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("closing log file: %w", err)
+	}
+	if l.keep == 0 {
+		if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing log file: %w", err)
+		}
+		return l.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", l.path, l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", l.path, i), fmt.Sprintf("%s.%d", l.path, i+1))
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("rotating log file: %w", err)
+	}
+	return l.open()
+}
+
+// Write writes p to the log file, starting every line with the current time.
+// The file is only rotated at the start of a line, so lines are not split between files
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	for start := 0; start < len(p); {
+		if l.lineStart {
+			if l.size >= l.maxSize {
+				if err := l.rotate(); err != nil {
+					return start, err
+				}
+			}
+			n, err := l.file.WriteString(time.Now().Format("2006-01-02 15:04:05 "))
+			l.size += int64(n)
+			if err != nil {
+				return start, err
+			}
+			l.lineStart = false
+		}
+		end := len(p)
+		for i := start; i < len(p); i++ {
+			if p[i] == '\n' {
+				end = i + 1
+				l.lineStart = true
+				break
+			}
+		}
+		n, err := l.file.Write(p[start:end])
+		l.size += int64(n)
+		if err != nil {
+			return start + n, err
+		}
+		start = end
+	}
+	return len(p), nil
+}
+
+// Close closes the log file
+func (l *rotatingLog) Close() error {
+	return l.file.Close()
+}
+
+// teeOutput copies everything that is written to stdout and stderr to w as well, so the output
+// of all operations and their errors end up in the log file. Errors of w are ignored, so the
+// console output continues if the log file cannot be written. The returned function waits
+// until all output was copied
+func teeOutput(w io.Writer) (func(), error) {
+	locked := &lockedWriter{w: w}
+	restoreStdout, err := tee(&os.Stdout, locked)
+	if err != nil {
+		return nil, err
+	}
+	restoreStderr, err := tee(&os.Stderr, locked)
+	if err != nil {
+		restoreStdout()
+		return nil, err
+	}
+	return func() {
+		restoreStderr()
+		restoreStdout()
+	}, nil
+}
+
+// lockedWriter serializes the writes of the stdout and stderr copies, as the log is not safe
+// for concurrent use
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write writes p to the underlying writer while holding the lock
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// tee replaces *file with a pipe and copies everything written to it to the original file and w
+func tee(file **os.File, w io.Writer) (func(), error) {
+	original := *file
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("redirecting output: %w", err)
+	}
+	*file = writer
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buffer := make([]byte, 32*1024)
+		logFailed := false
+		for {
+			n, err := reader.Read(buffer)
+			if n > 0 {
+				original.Write(buffer[:n])
+				if !logFailed {
+					if _, err := w.Write(buffer[:n]); err != nil {
+						fmt.Fprintf(original, "⚠ Could not write log file, continuing without it: %v\n", err)
+						logFailed = true
+					}
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		*file = original
+		writer.Close()
+		<-done
+		reader.Close()
+	}, nil
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
-	"encoding/json"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+	"sort"
+	"strconv"
+	"text/template"
 	"time"
 
+	"github.com/forceu/jellyfinmanager/api/cassette"
 	"github.com/forceu/jellyfinmanager/api/jellyfin"
 	"github.com/forceu/jellyfinmanager/api/tvdb"
 	"github.com/forceu/jellyfinmanager/environment"
@@ -16,98 +24,830 @@ import (
 
 const (
 	appVersion = "1.0.0"
+	// defaultUserAgent is sent to Jellyfin and TVDB unless -user-agent is set
+	defaultUserAgent = "JellyfinManager/" + appVersion + " (+https://github.com/forceu/jellyfinmanager)"
 )
 
 func main() {
 	// Command-line flags
 	var (
-		serverURL       = flag.String("server", "", "Jellyfin server URL (e.g., http://localhost:8096)")
-		apiKey          = flag.String("apikey", "", "Jellyfin API key")
-		userName        = flag.String("user", "", "Jellyfin user name")
-		tvdbAPIKey      = flag.String("tvdb-apikey", "", "TVDB API key (for missing episodes check)")
-		backupFile      = flag.String("file", environment.DefaultBackupFile, "Backup file path")
-		backup          = flag.Bool("backup", false, "Perform backup")
-		restore         = flag.Bool("restore", false, "Perform restore")
-		findMissing     = flag.Bool("find-missing", false, "Find missing episodes using TVDB")
-		includeSpecials = flag.Bool("include-specials", false, "Include special episodes in missing episode check")
+		serverURL              = flag.String("server", "", "Jellyfin server URL (e.g., http://localhost:8096)")
+		apiKey                 = flag.String("apikey", "", "Jellyfin API key")
+		userName               = flag.String("user", "", "Jellyfin user name")
+		userID                 = flag.String("user-id", "", "Jellyfin user ID, skips the lookup of all users")
+		tvdbAPIKey             = flag.String("tvdb-apikey", "", "TVDB API key (for missing episodes check)")
+		tvdbLanguage           = flag.String("tvdb-language", "", "Language for TVDB episode names, e.g. deu or fra (default: original language)")
+		useKeyring             = flag.Bool("use-keyring", false, "Read the API keys from the system keyring and offer to store keys that are passed in")
+		backupFile             = flag.String("file", environment.DefaultBackupFile, "Backup file path")
+		compressLevel          = flag.Int("compress-level", defaultCompressLevel, "Gzip compression level from 1 (fastest) to 9 (smallest), used if the backup file ends with .gz")
+		backupFormat           = flag.String("format", "", "Backup file format, json or xml (default: detected from the file extension, otherwise json)")
+		compact                = flag.Bool("compact", false, "Write the backup without indentation to reduce its size")
+		sortItems              = flag.Bool("sort", false, "Sort the backup by type, series, season, episode and name, so it can be compared between runs")
+		watchedThreshold       = flag.Float64("watched-threshold", 0, "Also back up unplayed items whose playback position is at least this fraction of the runtime, e.g. 0.9")
+		sinceLastBackup        = flag.Bool("since-last-backup", false, "Add items played since the existing backup was created to it, instead of replacing it")
+		yearFrom               = flag.Int("year-from", 0, "Only back up items produced in this year or later")
+		yearTo                 = flag.Int("year-to", 0, "Only back up items produced in this year or earlier")
+		allowEmpty             = flag.Bool("allow-empty", false, "Write the backup even if no watched items were found")
+		includeImages          = flag.Bool("include-images", false, "Store the tag of the primary image of each item in the backup")
+		includeInProgress      = flag.Bool("include-in-progress", false, "Also back up or migrate items that were started but not finished, with their resume position, so Continue Watching can be restored")
+		includeFavorites       = flag.Bool("include-favorites", false, "Also back up or migrate which of the items are favorites, so they can be marked as favorites again on restore")
+		pageWorkers            = flag.Int("page-workers", 1, "Number of pages of the library that are fetched at the same time, e.g. 4 for large libraries")
+		idleConnections        = flag.Int("idle-connections", defaultIdleConnections, "Number of idle connections kept open per server, so they can be reused by later requests")
+		dialTimeout            = flag.Duration("dial-timeout", defaultDialTimeout, "How long connecting to Jellyfin or TVDB may take before the request fails")
+		retries                = flag.Int("retries", jellyfin.DefaultRetries, "How often a Jellyfin request is repeated after a network error or a temporary error status like 502 or 503, 0 to disable")
+		shrinkThreshold        = flag.Float64("shrink-threshold", 0.5, "Do not replace an existing backup if the new one has less than this fraction of its items, 0 disables the check")
+		allowShrink            = flag.Bool("allow-shrink", false, "Replace an existing backup even if the new one is much smaller")
+		cassetteFile           = flag.String("cassette", "", "Replay recorded HTTP interactions from this file instead of contacting the servers")
+		userAgent              = flag.String("user-agent", defaultUserAgent, "User-Agent header sent to Jellyfin and TVDB")
+		deadline               = flag.Duration("deadline", 0, "Stop the run after this time, e.g. 10m, and print what was done so far (default: no deadline)")
+		logFile                = flag.String("log-file", "", "Also write the output to this file, with the time at the start of every line")
+		runReportFile          = flag.String("run-report", "", "Write the command, times, status, counts and errors of the run as JSON to this file after every run")
+		logMaxSize             = flag.Int64("log-max-size", 10, "Size in MB at which the -log-file is rotated")
+		logKeep                = flag.Int("log-keep", 5, "Number of rotated -log-file files to keep")
+		envFile                = flag.String("env-file", "", "Load environment variables from this file (default: .env in the working directory, if present)")
+		configPath             = flag.String("config", "", "Config file with named server profiles and additional providers")
+		profileName            = flag.String("profile", "", "Name of the server profile from the config file to use")
+		allProfiles            = flag.Bool("all-profiles", false, "Run the operation for all server profiles from the config file")
+		backup                 = flag.Bool("backup", false, "Perform backup")
+		dryRun                 = flag.Bool("dry-run", false, "Only show what would be done, without writing or changing anything")
+		restore                = flag.Bool("restore", false, "Perform restore")
+		unwatch                = flag.Bool("unwatch", false, "Mark all items of the backup file that can be found on the server as unwatched. Asks for confirmation unless -yes is set")
+		retryUnmatched         = flag.Bool("retry-unmatched", false, "Retry items that could not be found during restore with relaxed name matching")
+		skipWatchedSeries      = flag.Bool("skip-watched-series", false, "Skip series that are already completely watched on the server during restore")
+		exactSeriesOnly        = flag.Bool("exact-series-only", false, "Don't restore episodes of series whose name has no exact match on the server, instead of using the closest search result")
+		noNameMatch            = flag.Bool("no-name-match", false, "Do not match items by name during restore if no provider ID matches")
+		matchPaths             = flag.Bool("match-paths", false, "Match items by file path during restore if no provider ID matches, for servers that use the same files")
+		normalizeProviderNames = flag.Bool("normalize-provider-names", false, "Before restoring, report provider names that are normalised when matching and malformed provider IDs in the backup")
+		previewNormalization   = flag.Bool("preview-normalization", false, "With -restore, compare matching by raw and by normalised provider IDs for every item of the backup, without changing anything")
+		renameMapFile          = flag.String("rename-map", "", "JSON file that maps series names of the backup or of Jellyfin to the name to search for, used by restore and find-missing, and optionally to the seasons find-missing checks")
+		chunkSize              = flag.Int("chunk-size", 0, "Pause after every this many items marked as watched during a restore (default: no pauses)")
+		restoreOrder           = flag.String("order", orderOldestFirst, "Order in which a restore marks items by their played date: oldest-first or newest-first")
+		chunkPause             = flag.Duration("chunk-pause", 5*time.Second, "How long to pause between chunks of -chunk-size")
+		diffOnly               = flag.Bool("diff-only", false, "List the items a restore would mark as watched and ask for confirmation before applying them")
+		assumeYes              = flag.Bool("yes", false, "Apply the changes of -diff-only, -clear-source-after and -unwatch without asking")
+		sourceServer           = flag.String("source-server", "", "Restore from this Jellyfin server directly instead of a backup file")
+		sourceAPIKey           = flag.String("source-apikey", "", "API key for -source-server")
+		sourceUser             = flag.String("source-user", "", "Username on -source-server (default: same as -user)")
+		sourceUserID           = flag.String("source-user-id", "", "User ID on -source-server, instead of -source-user")
+		clearSourceAfter       = flag.Bool("clear-source-after", false, "After all items were restored from -source-server, mark them as unwatched there. Asks for confirmation unless -yes is set")
+		importCSV              = flag.String("import-csv", "", "Mark the items of this CSV file as watched, as favorites and set their ratings")
+		exportICS              = flag.String("export-ics", "", "Write the watched items as all-day events on their played date to this iCalendar file")
+		findMissing            = flag.Bool("find-missing", false, "Find missing episodes using TVDB")
+		includeSpecials        = flag.Bool("include-specials", false, "Include special episodes in missing episode check")
+		skipMovieSpecials      = flag.Bool("skip-movie-specials", false, "Exclude episodes that TVDB flags as movies from missing episode check")
+		seasonFilter           = flag.String("seasons", "", "Comma-separated list of seasons to check for missing episodes, e.g. 19,20 (default: all)")
+		defaultRuntime         = flag.Int("default-runtime", 0, "Runtime in minutes assumed for TVDB episodes without one when detecting merged multi-part episodes")
+		crossSeasonMerge       = flag.Bool("allow-cross-season-merge", false, "Also detect multi-part files that contain the last episode of one season and the first of the next")
+		detectSplitSeasons     = flag.Bool("detect-split-seasons", false, "Compare seasons split into parts in Jellyfin or on TVDB with the combined season on the other side, if the parts continue the episode numbers")
+		tryAlternateOrder      = flag.Bool("try-alternate-order", false, "Don't report episodes that exist in Jellyfin under their number in another TVDB order, e.g. the DVD order")
+		showOverviews          = flag.Bool("show-overviews", false, "Print the synopsis below each missing episode")
+		reportComplete         = flag.Bool("report-complete", false, "Also print series without missing episodes")
+		groupBy                = flag.String("group-by", groupBySeries, "List missing episodes per series or across all series by airdate, most recent first")
+		episodeFormat          = flag.String("episode-format", defaultEpisodeFormat, "Template for season and episode numbers of missing episodes, e.g. {season}x{episode}")
+		episodeTemplate        = flag.String("template", "", "Go text/template for each missing episode in the output, e.g. \"{{.SeriesName}} {{.SeasonNumber}}x{{.EpisodeNumber}}\"")
+		unresolvedFile         = flag.String("unresolved-file", "", "Write series that could not be checked because they have no TVDB ID to this file")
+		checkpointFile         = flag.String("checkpoint", "", "Save the progress of find-missing to this file, so it can be resumed with -resume")
+		resume                 = flag.Bool("resume", false, "Resume find-missing from the progress saved with -checkpoint")
+		reportFile             = flag.String("report-file", "", "Write the missing episodes to this file as JSON (.json), Markdown (.md) or plain text. For -validate-provider-ids, the file is always JSON")
+		outputFormat           = flag.String("output", "", "Format of the -report-file for -find-missing: json, markdown, text or sonarr-list (default: detected from the file extension). For -list-users and -compare-users: text or json")
+		baselineFile           = flag.String("baseline", "", "JSON report of an earlier find-missing run to show which episodes were resolved and which are newly missing")
+		searchListFile         = flag.String("search-list", "", "Write a search query for each missing episode to this file, e.g. to paste into an indexer")
+		searchFormat           = flag.String("search-format", defaultSearchFormat, "Template for each line of -search-list with {series}, {name}, {season} and {episode}")
+		progressInterval       = flag.Duration("progress-interval", 5*time.Second, "How often find-missing shows a status line with the progress in the terminal, 0 to disable")
+		validateProviderIDs    = flag.Bool("validate-provider-ids", false, "Report which provider IDs the movies and episodes in the library have")
+		listUsers              = flag.Bool("list-users", false, "List the names and IDs of all users on the server")
+		compareUsers           = flag.String("compare-users", "", "Compare the watched items of two users, given as USER_A,USER_B, without changing anything")
+		printSchema            = flag.Bool("print-schema", false, "Print the JSON Schema of JSON backup files and exit")
+		lintFile               = flag.String("lint", "", "Check this backup file for problems without contacting a server")
 	)
+	var excludePatterns stringList
+	flag.Var(&excludePatterns, "exclude", "Skip series whose name matches this glob, or regular expression if prefixed with re:, in find-missing. Can be repeated")
 
 	flag.Parse()
 
-	// Validate required flags
-	if *serverURL == "" {
-		*serverURL = os.Getenv("JELLYFIN_SERVER")
+	// The log is opened first, so errors in the other flags and the configuration are logged as well.
+	// The output is copied to the log file until closeLog is called
+	if *logFile != "" {
+		if *logMaxSize <= 0 || *logKeep < 0 {
+			fmt.Println("Error: -log-max-size must be positive and -log-keep must not be negative")
+			exit(1)
+		}
+		log, err := openRotatingLog(*logFile, *logMaxSize*1024*1024, *logKeep)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		restoreOutput, err := teeOutput(log)
+		if err != nil {
+			log.Close()
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		closeLog = func() {
+			restoreOutput()
+			log.Close()
+		}
 	}
-	if *apiKey == "" {
-		*apiKey = os.Getenv("JELLYFIN_API_KEY")
+	defer closeLog()
+
+	if *printSchema {
+		if err := printBackupSchema(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		return
 	}
-	if *userName == "" {
-		*userName = os.Getenv("JELLYFIN_USER")
+	if *lintFile != "" {
+		if err := performLint(*lintFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	// Load .env before falling back to environment variables, flags still take precedence
+	if *envFile != "" {
+		err := environment.LoadEnvFile(*envFile, true)
+		if err != nil {
+			fmt.Printf("Error loading env file: %v\n", err)
+			exit(1)
+		}
+	} else {
+		err := environment.LoadEnvFile(environment.DefaultEnvFile, false)
+		if err != nil {
+			fmt.Printf("Error loading %s: %v\n", environment.DefaultEnvFile, err)
+			exit(1)
+		}
 	}
+
 	if *tvdbAPIKey == "" {
 		*tvdbAPIKey = os.Getenv("TVDB_API_KEY")
 	}
+	if *configPath == "" {
+		*configPath = os.Getenv("JELLYFIN_CONFIG")
+	}
 
-	if *serverURL == "" || *apiKey == "" || *userName == "" {
-		fmt.Println("Error: Missing required configuration")
-		fmt.Println("\nUsage:")
-		fmt.Println("  Backup:        jellyfinmanager -backup -server URL -apikey KEY -user NAME [-file backup.json]")
-		fmt.Println("  Restore:       jellyfinmanager -restore -server URL -apikey KEY -user NAME [-file backup.json]")
-		fmt.Println("  Find Missing:  jellyfinmanager -find-missing -server URL -apikey KEY -user NAME -tvdb-apikey KEY [-include-specials]")
-		fmt.Println("\nOr set environment variables:")
-		fmt.Println("  JELLYFIN_SERVER, JELLYFIN_API_KEY, JELLYFIN_USER, TVDB_API_KEY")
-		os.Exit(1)
+	// Select the servers to run the operation for
+	var profiles []profile
+	if *configPath != "" {
+		config, err := loadConfig(*configPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		config.registerProviders()
+		// A config file with only providers is used with the server given on the command line
+		if len(config.Profiles) == 0 && *profileName == "" && !*allProfiles {
+			profiles = []profile{{}}
+		} else {
+			if *profileName == "" && !*allProfiles {
+				fmt.Println("Error: Please specify -profile NAME or -all-profiles when using a config file")
+				exit(1)
+			}
+			profiles, err = selectProfiles(config.Profiles, *profileName, *allProfiles)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+		}
+	} else if *profileName != "" || *allProfiles {
+		fmt.Println("Error: -profile and -all-profiles require -config FILE")
+		exit(1)
+	} else {
+		profiles = []profile{{}}
 	}
 
-	config := models.Config{
-		ServerURL: strings.TrimSuffix(*serverURL, "/"),
-		APIKey:    *apiKey,
-		UserName:  *userName,
+	var targets []target
+	for _, p := range profiles {
+		t := target{
+			Name: p.Name,
+			Config: models.Config{
+				ServerURL: resolveSetting(*serverURL, p.ServerURL, "JELLYFIN_SERVER"),
+				APIKey:    resolveSetting(*apiKey, p.APIKey, "JELLYFIN_API_KEY"),
+				UserID:    resolveSetting(*userID, p.UserID, "JELLYFIN_USER_ID"),
+				UserName:  resolveSetting(*userName, p.UserName, "JELLYFIN_USER"),
+			},
+			BackupFile: *backupFile,
+		}
+		if p.BackupFile != "" {
+			t.BackupFile = p.BackupFile
+		} else if len(profiles) > 1 {
+			t.BackupFile = profileBackupFile(*backupFile, p.Name)
+		}
+
+		if *useKeyring && t.Config.ServerURL != "" {
+			description := "Jellyfin API key for " + t.Config.ServerURL
+			t.Config.APIKey = keyringCredential(jellyfinKeyringAccount(t.Config.ServerURL), t.Config.APIKey, description)
+		}
+
+		// Listing and comparing users does not need a user, so a wrong one must not prevent it
+		if *listUsers || *compareUsers != "" {
+			t.Config.UserName = ""
+			t.Config.UserID = ""
+		}
+
+		if t.Config.ServerURL == "" || t.Config.APIKey == "" || (t.Config.UserName == "" && t.Config.UserID == "" && !*listUsers && *compareUsers == "") {
+			if t.Name != "" {
+				fmt.Printf("Error: Missing required configuration for profile %s\n", t.Name)
+			} else {
+				fmt.Println("Error: Missing required configuration")
+			}
+			printUsage()
+			exit(1)
+		}
+		targets = append(targets, t)
 	}
 
-	client, err := jellyfin.NewClient(config)
-	if err != nil {
-		fmt.Printf("Error logging in to Jellyfin: %v\n", err)
-		os.Exit(1)
+	// The run ID identifies this run in the session list and logs of the Jellyfin server
+	runID := newRunID()
+
+	// The deadline applies to the whole run, so scheduled runs finish before the next one starts
+	runCtx := context.Background()
+	if *deadline > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, *deadline)
+		defer cancel()
 	}
 
-	// Execute requested operation
-	if *backup {
-		err = performBackup(client, *backupFile)
+	// List and comparison documents in JSON are written to stdout alone, so they can be piped to jq
+	jsonDocument := (*listUsers || *compareUsers != "") && *outputFormat == reportJSON
+
+	jellyfinOptions := []jellyfin.Option{
+		jellyfin.WithDeviceID("jellyfinmanager-" + runID),
+		jellyfin.WithUserAgent(*userAgent),
+		jellyfin.WithContext(runCtx),
+		jellyfin.WithBusyNotice(func(err error, wait time.Duration) {
+			fmt.Fprintf(statusOutput(jsonDocument), "⚠ %v, retrying in %s\n", err, wait)
+		}),
+	}
+	tvdbOptions := []tvdb.Option{tvdb.WithUserAgent(*userAgent), tvdb.WithContext(runCtx)}
+	if *tvdbLanguage != "" {
+		tvdbOptions = append(tvdbOptions, tvdb.WithLanguage(*tvdbLanguage))
+	}
+	if *pageWorkers < 1 {
+		fmt.Println("Error: -page-workers must be at least 1")
+		exit(1)
+	}
+	jellyfinOptions = append(jellyfinOptions, jellyfin.WithPageWorkers(*pageWorkers))
+	if *retries < 0 {
+		fmt.Println("Error: -retries must not be negative")
+		exit(1)
+	}
+	jellyfinOptions = append(jellyfinOptions, jellyfin.WithRetries(*retries))
+	if *idleConnections < 1 || *dialTimeout <= 0 {
+		fmt.Println("Error: -idle-connections must be at least 1 and -dial-timeout must be positive")
+		exit(1)
+	}
+	transport := newTransport(*idleConnections, *dialTimeout)
+	jellyfinOptions = append(jellyfinOptions, jellyfin.WithTransport(transport))
+	tvdbOptions = append(tvdbOptions, tvdb.WithTransport(transport))
+	if *cassetteFile != "" {
+		recorded, err := cassette.Load(*cassetteFile)
 		if err != nil {
-			fmt.Printf("Backup failed: %v\n", err)
-			os.Exit(1)
+			fmt.Printf("Error loading cassette: %v\n", err)
+			exit(1)
+		}
+		jellyfinOptions = append(jellyfinOptions, jellyfin.WithTransport(recorded))
+		tvdbOptions = append(tvdbOptions, tvdb.WithTransport(recorded))
+	}
+
+	// Validate the requested operation before connecting to any server
+	var operationName string
+	// command is the name of the operation in the run report
+	var command string
+	var operation func(client *jellyfin.Client, backupFile string) error
+	if *backup {
+		if *compressLevel < gzip.BestSpeed || *compressLevel > gzip.BestCompression {
+			fmt.Printf("Error: -compress-level must be between %d and %d\n", gzip.BestSpeed, gzip.BestCompression)
+			exit(1)
+		}
+		if *watchedThreshold < 0 || *watchedThreshold > 1 {
+			fmt.Println("Error: -watched-threshold must be between 0 and 1")
+			exit(1)
+		}
+		if *shrinkThreshold < 0 || *shrinkThreshold > 1 {
+			fmt.Println("Error: -shrink-threshold must be between 0 and 1")
+			exit(1)
+		}
+		if *yearFrom < 0 || *yearTo < 0 || (*yearFrom != 0 && *yearTo != 0 && *yearFrom > *yearTo) {
+			fmt.Println("Error: -year-from must not be after -year-to")
+			exit(1)
+		}
+		if _, err := parseBackupFormat(*backupFormat, *backupFile); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		if *includeImages {
+			jellyfinOptions = append(jellyfinOptions, jellyfin.WithPrimaryImageTags())
+		}
+		operationName = "Backup"
+		command = "backup"
+		operation = func(client *jellyfin.Client, backupFile string) error {
+			format, err := parseBackupFormat(*backupFormat, backupFile)
+			if err != nil {
+				return err
+			}
+			options := backupOptions{
+				DryRun:          *dryRun,
+				CompressLevel:   *compressLevel,
+				Format:          format,
+				Threshold:       *watchedThreshold,
+				SinceLastBackup: *sinceLastBackup,
+				Compact:         *compact,
+				YearFrom:        *yearFrom,
+				YearTo:          *yearTo,
+				AllowEmpty:      *allowEmpty,
+				Sort:            *sortItems,
+				InProgress:      *includeInProgress,
+				Favorites:       *includeFavorites,
+			}
+			if !*allowShrink {
+				options.ShrinkThreshold = *shrinkThreshold
+			}
+			return performBackup(client, backupFile, options)
 		}
 	} else if *restore {
-		err = performRestore(client, *backupFile)
+		options := restoreOptions{
+			RetryUnmatched:     *retryUnmatched,
+			SkipWatchedSeries:  *skipWatchedSeries,
+			DiffOnly:           *diffOnly,
+			AssumeYes:          *assumeYes,
+			ClearSourceAfter:   *clearSourceAfter,
+			ExactSeriesOnly:    *exactSeriesOnly,
+			NoNameMatch:        *noNameMatch,
+			MatchPaths:         *matchPaths,
+			CheckProviderNames: *normalizeProviderNames,
+			InProgress:         *includeInProgress,
+			IncludeFavorites:   *includeFavorites,
+			DryRun:             *dryRun,
+		}
+		renames, err := loadSeriesMap(*renameMapFile)
+		if err != nil {
+			fmt.Printf("Error: Invalid -rename-map: %v\n", err)
+			exit(1)
+		}
+		options.RenameSeries = renames
+		if *noNameMatch && *retryUnmatched {
+			fmt.Println("Error: -retry-unmatched matches by name and cannot be used with -no-name-match")
+			exit(1)
+		}
+		order, err := parseRestoreOrder(*restoreOrder)
 		if err != nil {
-			fmt.Printf("Restore failed: %v\n", err)
-			os.Exit(1)
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		options.Order = order
+		if *chunkSize < 0 || *chunkPause < 0 {
+			fmt.Println("Error: -chunk-size and -chunk-pause must not be negative")
+			exit(1)
+		}
+		if *chunkSize > 0 {
+			options.Chunks = &chunker{Size: *chunkSize, Pause: *chunkPause}
+		}
+		if *clearSourceAfter && *sourceServer == "" {
+			fmt.Println("Error: -clear-source-after requires -source-server URL")
+			exit(1)
+		}
+		operationName = "Restore"
+		command = "restore"
+		operation = func(client *jellyfin.Client, backupFile string) error {
+			return performRestore(client, backupFile, options)
+		}
+
+		if *sourceServer != "" {
+			// The user on the source server is the same as on the target unless specified otherwise
+			sourceConfig := models.Config{
+				ServerURL: *sourceServer,
+				APIKey:    *sourceAPIKey,
+				UserID:    *sourceUserID,
+				UserName:  *sourceUser,
+			}
+			if sourceConfig.APIKey == "" {
+				fmt.Println("Error: -source-server requires -source-apikey KEY")
+				exit(1)
+			}
+			command = "migrate"
+			operation = func(client *jellyfin.Client, _ string) error {
+				config := sourceConfig
+				if config.UserName == "" && config.UserID == "" {
+					config.UserName = client.GetConfig().UserName
+				}
+				source, err := jellyfin.NewClient(config, jellyfinOptions...)
+				if err != nil {
+					return fmt.Errorf("logging in to source server: %w", err)
+				}
+				return performMigrate(source, client, options)
+			}
+		}
+		if *previewNormalization {
+			if *sourceServer != "" {
+				fmt.Println("Error: -preview-normalization reads a backup file and cannot be used with -source-server")
+				exit(1)
+			}
+			operationName = "Normalisation preview"
+			command = "preview-normalization"
+			operation = func(client *jellyfin.Client, backupFile string) error {
+				return performPreviewNormalization(client, backupFile)
+			}
+		}
+	} else if *unwatch {
+		if *noNameMatch && *retryUnmatched {
+			fmt.Println("Error: -retry-unmatched matches by name and cannot be used with -no-name-match")
+			exit(1)
+		}
+		renames, err := loadSeriesMap(*renameMapFile)
+		if err != nil {
+			fmt.Printf("Error: Invalid -rename-map: %v\n", err)
+			exit(1)
+		}
+		options := unwatchOptions{
+			Matching: restoreOptions{
+				RetryUnmatched:  *retryUnmatched,
+				ExactSeriesOnly: *exactSeriesOnly,
+				NoNameMatch:     *noNameMatch,
+				MatchPaths:      *matchPaths,
+				RenameSeries:    renames,
+			},
+			AssumeYes: *assumeYes,
+			DryRun:    *dryRun,
+		}
+		operationName = "Unwatch"
+		command = "unwatch"
+		operation = func(client *jellyfin.Client, backupFile string) error {
+			return performUnwatch(client, backupFile, options)
 		}
 	} else if *findMissing {
+		if *useKeyring {
+			*tvdbAPIKey = keyringCredential(tvdbKeyringAccount, *tvdbAPIKey, "TVDB API key")
+		}
 		if *tvdbAPIKey == "" {
 			fmt.Println("Error: TVDB API key required for finding missing episodes")
 			fmt.Println("Use -tvdb-apikey flag or set TVDB_API_KEY environment variable")
-			os.Exit(1)
+			exit(1)
+		}
+
+		if *resume && *checkpointFile == "" {
+			fmt.Println("Error: -resume requires -checkpoint FILE")
+			exit(1)
+		}
+
+		if *outputFormat != "" && *reportFile == "" {
+			fmt.Println("Error: -output requires -report-file PATH")
+			exit(1)
+		}
+		reportFormat, err := parseReportFormat(*outputFormat, *reportFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		if err := validateEpisodeFormat(*episodeFormat); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		if err := validateSearchFormat(*searchFormat); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+
+		var tmpl *template.Template
+		if *episodeTemplate != "" {
+			tmpl, err = parseEpisodeTemplate(*episodeTemplate)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				exit(1)
+			}
+		}
+
+		if *groupBy != groupBySeries && *groupBy != groupByAirDate {
+			fmt.Printf("Error: -group-by must be %s or %s\n", groupBySeries, groupByAirDate)
+			exit(1)
+		}
+		if *defaultRuntime < 0 {
+			fmt.Println("Error: -default-runtime must not be negative")
+			exit(1)
+		}
+
+		seasons, err := parseSeasons(*seasonFilter)
+		if err != nil {
+			fmt.Printf("Error: Invalid -seasons value: %v\n", err)
+			exit(1)
+		}
+
+		exclude, err := parseSeriesPatterns(excludePatterns)
+		if err != nil {
+			fmt.Printf("Error: Invalid -exclude value: %v\n", err)
+			exit(1)
+		}
+		renames, err := loadSeriesMap(*renameMapFile)
+		if err != nil {
+			fmt.Printf("Error: Invalid -rename-map: %v\n", err)
+			exit(1)
 		}
 
-		err = performFindMissing(client, *tvdbAPIKey, *includeSpecials)
+		options := findMissingOptions{
+			IncludeSpecials:    *includeSpecials,
+			SkipMovieSpecials:  *skipMovieSpecials,
+			Seasons:            seasons,
+			UnresolvedFile:     *unresolvedFile,
+			CheckpointFile:     *checkpointFile,
+			Resume:             *resume,
+			ReportFile:         *reportFile,
+			ReportFormat:       reportFormat,
+			Baseline:           *baselineFile,
+			ProgressInterval:   *progressInterval,
+			ShowOverviews:      *showOverviews,
+			DefaultRuntime:     *defaultRuntime,
+			CrossSeasonMerge:   *crossSeasonMerge,
+			DetectSplitSeasons: *detectSplitSeasons,
+			ReportComplete:     *reportComplete,
+			TryAlternateOrder:  *tryAlternateOrder,
+			GroupBy:            *groupBy,
+			Template:           tmpl,
+			Exclude:            exclude,
+			SeriesMap:          renames,
+			DryRun:             *dryRun,
+			EpisodeFormat:      *episodeFormat,
+			SearchList:         *searchListFile,
+			SearchFormat:       *searchFormat,
+		}
+		tvdbClient := tvdb.NewClient(*tvdbAPIKey, tvdbOptions...)
+		operationName = "Find missing episodes"
+		command = "find-missing"
+		operation = func(client *jellyfin.Client, _ string) error {
+			return performFindMissing(runCtx, client, tvdbClient, options)
+		}
+	} else if *validateProviderIDs {
+		operationName = "Validating provider IDs"
+		command = "validate-provider-ids"
+		operation = func(client *jellyfin.Client, _ string) error {
+			return performValidateProviderIDs(client, *reportFile)
+		}
+	} else if *importCSV != "" {
+		operationName = "CSV import"
+		command = "import-csv"
+		operation = func(client *jellyfin.Client, _ string) error {
+			return performImportCSV(client, *importCSV)
+		}
+	} else if *exportICS != "" {
+		operationName = "ICS export"
+		command = "export-ics"
+		operation = func(client *jellyfin.Client, _ string) error {
+			return performExportICS(client, *exportICS)
+		}
+	} else if *listUsers {
+		if *outputFormat != "" && *outputFormat != reportText && *outputFormat != reportJSON {
+			fmt.Println("Error: -list-users only supports -output text or json")
+			exit(1)
+		}
+		operationName = "Listing users"
+		command = "list-users"
+		operation = func(client *jellyfin.Client, _ string) error {
+			return performListUsers(client, *outputFormat)
+		}
+	} else if *compareUsers != "" {
+		userA, userB, err := parseUserPair(*compareUsers)
 		if err != nil {
-			fmt.Printf("Find missing episodes failed: %v\n", err)
-			os.Exit(1)
+			fmt.Printf("Error: %v\n", err)
+			exit(1)
+		}
+		if *outputFormat != "" && *outputFormat != reportText && *outputFormat != reportJSON {
+			fmt.Println("Error: -compare-users only supports -output text or json")
+			exit(1)
+		}
+		operationName = "Comparing users"
+		command = "compare-users"
+		operation = func(client *jellyfin.Client, _ string) error {
+			return performCompareUsers(client, userA, userB, *outputFormat)
 		}
 	} else {
-		fmt.Println("Error: Please specify -backup, -restore, or -find-missing")
-		os.Exit(1)
+		fmt.Println("Error: Please specify -backup, -restore, -unwatch, -import-csv, -export-ics, -find-missing, -validate-provider-ids, -list-users or -compare-users")
+		exit(1)
+	}
+
+	// The run report is only collected if it is written
+	var report *runReport
+	if *runReportFile != "" {
+		report = &runReport{
+			Command:   command,
+			RunID:     runID,
+			Version:   appVersion,
+			StartedAt: time.Now(),
+			Targets:   []runTarget{},
+		}
+	}
+
+	// Execute requested operation for every selected server
+	fmt.Fprintf(statusOutput(jsonDocument), "Run ID: %s\n", runID)
+	failed := false
+	for _, t := range targets {
+		if len(targets) > 1 {
+			fmt.Fprintf(statusOutput(jsonDocument), "\n##### Profile: %s #####\n", t.Name)
+		}
+		report.startTarget(t.Name, t.Config.ServerURL, t.Config.UserName)
+
+		client, err := jellyfin.NewClient(t.Config, jellyfinOptions...)
+		if err != nil {
+			fmt.Fprintf(statusOutput(jsonDocument), "[%s] Error logging in to Jellyfin: %v\n", runID, err)
+			report.finishTarget(fmt.Errorf("logging in to Jellyfin: %w", err))
+			failed = true
+			continue
+		}
+		if client.GetConfig().ServerVersion != "" {
+			fmt.Fprintf(statusOutput(jsonDocument), "Connected to Jellyfin %s\n", client.GetConfig().ServerVersion)
+		} else {
+			fmt.Fprintln(statusOutput(jsonDocument), "⚠ Could not detect Jellyfin version, assuming a current server")
+		}
+
+		// Only -list-users and -compare-users work without a user of their own
+		if !*listUsers && *compareUsers == "" {
+			if err := client.RequireUserID(); err != nil {
+				fmt.Fprintf(statusOutput(jsonDocument), "[%s] Error logging in to Jellyfin: %v\n", runID, err)
+				report.finishTarget(fmt.Errorf("logging in to Jellyfin: %w", err))
+				failed = true
+				continue
+			}
+		}
+
+		err = operation(client, t.BackupFile)
+		if err != nil {
+			fmt.Fprintf(statusOutput(jsonDocument), "[%s] %s failed: %v\n", runID, operationName, err)
+			failed = true
+		}
+		report.finishTarget(err)
+	}
+	if report != nil {
+		if err := report.write(*runReportFile); err != nil {
+			fmt.Fprintf(statusOutput(jsonDocument), "⚠ Could not write run report: %v\n", err)
+		}
+	}
+	if failed {
+		exit(1)
 	}
 }
 
-func performBackup(client *jellyfin.Client, filename string) error {
+// closeLog stops copying the output to the -log-file and closes it
+var closeLog = func() {}
+
+// exit closes the log before exiting, as deferred functions do not run on os.Exit and the
+// output that is still being copied would be lost
+func exit(code int) {
+	closeLog()
+	os.Exit(code)
+}
+
+// statusOutput returns where messages about the run are written, e.g. the run ID. They go to
+// stderr if the operation prints a JSON document, which would otherwise not be valid JSON.
+// os.Stdout is looked up on every call, as it is replaced while writing a -log-file
+func statusOutput(jsonDocument bool) io.Writer {
+	if jsonDocument {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// newRunID returns a short random ID for correlating the output of a run with the server logs
+func newRunID() string {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().Unix(), 16)
+	}
+	return hex.EncodeToString(id)
+}
+
+// target is a Jellyfin server and user that the selected operation is run for
+type target struct {
+	// Name is the name of the profile, empty if no config file is used
+	Name       string
+	Config     models.Config
+	BackupFile string
+}
+
+// resolveSetting returns the first non-empty value of the command-line flag,
+// the profile from the config file and the environment variable
+func resolveSetting(flagValue, profileValue, envName string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if profileValue != "" {
+		return profileValue
+	}
+	return os.Getenv(envName)
+}
+
+// printUsage prints how to call the tool
+func printUsage() {
+	fmt.Println("\nUsage:")
+	fmt.Println("  Backup:        jellyfinmanager -backup -server URL -apikey KEY -user NAME [-file backup.json[.gz]] [-format json|xml] [-compress-level 1-9] [-watched-threshold 0.9] [-since-last-backup] [-year-from YEAR] [-year-to YEAR] [-compact] [-sort] [-include-images] [-include-in-progress] [-include-favorites] [-page-workers N] [-allow-empty] [-shrink-threshold 0.5 | -allow-shrink] [-dry-run]")
+	fmt.Println("  Restore:       jellyfinmanager -restore -server URL -apikey KEY -user NAME [-file backup.json] [-retry-unmatched] [-skip-watched-series] [-exact-series-only] [-rename-map FILE] [-no-name-match] [-match-paths] [-normalize-provider-names] [-preview-normalization] [-order oldest-first|newest-first] [-chunk-size N [-chunk-pause 5s]] [-diff-only [-yes] | -dry-run]")
+	fmt.Println("  Migrate:       jellyfinmanager -restore -server URL -apikey KEY -user NAME -source-server URL -source-apikey KEY [-source-user NAME] [-include-in-progress] [-include-favorites] [-clear-source-after] [-retry-unmatched] [-skip-watched-series] [-exact-series-only] [-rename-map FILE] [-no-name-match] [-match-paths] [-normalize-provider-names] [-order oldest-first|newest-first] [-chunk-size N [-chunk-pause 5s]] [-diff-only [-yes] | -dry-run]")
+	fmt.Println("  Unwatch:       jellyfinmanager -unwatch -server URL -apikey KEY -user NAME [-file backup.json] [-retry-unmatched] [-exact-series-only] [-rename-map FILE] [-no-name-match] [-match-paths] [-yes | -dry-run]")
+	fmt.Println("  Import CSV:    jellyfinmanager -import-csv FILE -server URL -apikey KEY -user NAME")
+	fmt.Println("  Export ICS:    jellyfinmanager -export-ics FILE -server URL -apikey KEY -user NAME")
+	fmt.Println("  Find Missing:  jellyfinmanager -find-missing -server URL -apikey KEY -user NAME -tvdb-apikey KEY [-include-specials] [-skip-movie-specials] [-tvdb-language CODE] [-seasons LIST] [-exclude PATTERN ...] [-rename-map FILE] [-default-runtime MINUTES] [-allow-cross-season-merge] [-detect-split-seasons] [-try-alternate-order] [-show-overviews] [-report-complete] [-group-by series|airdate] [-episode-format TEMPLATE] [-template TEMPLATE] [-unresolved-file PATH] [-checkpoint FILE [-resume]] [-report-file PATH [-output json|markdown|text|sonarr-list]] [-baseline FILE] [-search-list FILE [-search-format TEMPLATE]] [-progress-interval 5s] [-dry-run]")
+	fmt.Println("  List Users:    jellyfinmanager -list-users -server URL -apikey KEY [-output text|json]")
+	fmt.Println("  Compare Users: jellyfinmanager -compare-users USER_A,USER_B -server URL -apikey KEY [-output text|json]")
+	fmt.Println("  Validate IDs:  jellyfinmanager -validate-provider-ids -server URL -apikey KEY -user NAME [-report-file report.json]")
+	fmt.Println("  Schema:        jellyfinmanager -print-schema")
+	fmt.Println("  Check backup:  jellyfinmanager -lint FILE")
+	fmt.Println("  Profiles:      jellyfinmanager -backup -config servers.json (-profile NAME | -all-profiles)")
+	fmt.Println("\nOr set environment variables (also read from a .env file or -env-file PATH):")
+	fmt.Println("  JELLYFIN_SERVER, JELLYFIN_API_KEY, JELLYFIN_USER, JELLYFIN_USER_ID, TVDB_API_KEY, JELLYFIN_CONFIG")
+	fmt.Println("\n-user-id can be used instead of -user if the API key may not list all users")
+}
+
+// backupOptions holds the settings for creating a backup
+type backupOptions struct {
+	DryRun bool
+	// CompressLevel is the gzip level used if the backup file ends with .gz
+	CompressLevel int
+	// Format is the file format of the backup, formatJSON or formatXML
+	Format string
+	// Threshold is the fraction of the runtime after which unplayed items are
+	// considered watched. Only played items are backed up if 0
+	Threshold float64
+	// SinceLastBackup adds the items played since the existing backup was created to it
+	SinceLastBackup bool
+	// Compact writes the backup without indentation
+	Compact bool
+	// YearFrom and YearTo limit the backup to items produced in these years. 0 means no limit
+	YearFrom int
+	YearTo   int
+	// AllowEmpty writes the backup even if no watched items were found
+	AllowEmpty bool
+	// Sort orders the items deterministically, so backups of an unchanged library are identical
+	Sort bool
+	// ShrinkThreshold is the fraction of the items of the existing backup that the new backup
+	// must at least have, otherwise it is not written. 0 disables the check
+	ShrinkThreshold float64
+	// InProgress also backs up items that were started but not finished, with their resume position
+	InProgress bool
+	// Favorites stores which of the backed up items are favorites
+	Favorites bool
+}
+
+func performBackup(client *jellyfin.Client, filename string, options backupOptions) error {
 	fmt.Printf("Fetching watched items from Jellyfin for user %s...\n", client.GetConfig().UserName)
-	watchedItems, err := client.GetWatchedItems()
+	userItems, err := client.GetUserItems()
 	if err != nil {
 		return fmt.Errorf("getting watched items: %w", err)
 	}
+	watchedItems := selectItems(userItems, options.Threshold, options.InProgress, options.Favorites)
+	if err := setContentHashes(watchedItems); err != nil {
+		return err
+	}
+	if options.InProgress {
+		fmt.Printf("Including %d items in progress\n", countInProgress(watchedItems))
+	}
+	if options.Favorites {
+		fmt.Printf("Including %d favorites\n", countFavorites(watchedItems))
+		if skipped := countUnselectedFavorites(userItems, watchedItems); skipped > 0 {
+			fmt.Printf("⚠ %d favorites were not backed up, as they are neither watched nor in progress\n", skipped)
+		}
+	}
+
+	if options.YearFrom != 0 || options.YearTo != 0 {
+		var excluded int
+		watchedItems, excluded = filterByYear(watchedItems, options.YearFrom, options.YearTo)
+		fmt.Printf("Excluded %d items outside of the production years %s\n", excluded, yearRange(options.YearFrom, options.YearTo))
+	}
+
+	if options.SinceLastBackup {
+		watchedItems, err = mergeWithLastBackup(client, filename, watchedItems)
+		if err != nil {
+			return err
+		}
+	}
+
+	// An empty result usually means a wrong user or missing permissions,
+	// which must not replace a good backup
+	if len(watchedItems) == 0 {
+		fmt.Println("⚠ 0 watched items found — check the user and the permissions of the API key")
+		if !options.AllowEmpty && !options.DryRun {
+			return fmt.Errorf("not writing an empty backup to %s, use -allow-empty to write it anyway", filename)
+		}
+	}
+
+	// Guard against replacing a good backup with a much smaller one, e.g. in scheduled runs
+	if options.ShrinkThreshold > 0 && !(len(watchedItems) == 0 && options.AllowEmpty) {
+		if err := checkShrink(filename, len(watchedItems), options.ShrinkThreshold); err != nil {
+			if !options.DryRun {
+				return err
+			}
+			fmt.Printf("⚠ %v\n", err)
+		}
+	}
+
+	recordCount("watched_items", len(watchedItems))
+	if options.DryRun {
+		printBackupSummary(watchedItems)
+		fmt.Printf("\nDry run: backup was not written to %s\n", filename)
+		return nil
+	}
+
+	if options.Sort {
+		sortWatchedItems(watchedItems)
+	}
 
 	backup := models.Backup{
 		CreatedAt:    time.Now(),
@@ -118,319 +858,641 @@ func performBackup(client *jellyfin.Client, filename string) error {
 		WatchedItems: watchedItems,
 	}
 
-	data, err := json.MarshalIndent(backup, "", "  ")
+	backup.Checksum, err = backup.CalculateChecksum()
+	if err != nil {
+		return fmt.Errorf("calculating checksum: %w", err)
+	}
+
+	data, err := marshalBackup(backup, options.Format, options.Compact)
 	if err != nil {
 		return fmt.Errorf("marshaling backup: %w", err)
 	}
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
+	filename, err = prepareBackupPath(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := writeBackupFile(filename, data, options.CompressLevel); err != nil {
 		return fmt.Errorf("writing backup file: %w", err)
 	}
 
 	fmt.Printf("✓ Backed up %d watched items to %s\n", len(watchedItems), filename)
+	if withoutDate := countWithoutPlayedDate(watchedItems); withoutDate > 0 {
+		fmt.Printf("○ %d items have no played date on the server, e.g. because they were marked as watched without playing them\n", withoutDate)
+	}
 	return nil
 }
 
-func performRestore(client *jellyfin.Client, filename string) error {
-	data, err := os.ReadFile(filename)
+// checkShrink returns an error if the existing backup has so many more items than the new one
+// that the new one has less than threshold of them. A missing or unreadable backup is not an error
+func checkShrink(filename string, count int, threshold float64) error {
+	existing, err := loadBackup(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("reading backup file: %w", err)
+		fmt.Printf("⚠ Could not read the existing backup to compare its size: %v\n", err)
+		return nil
 	}
-
-	var backup models.Backup
-	if err := json.Unmarshal(data, &backup); err != nil {
-		return fmt.Errorf("unmarshaling backup: %w", err)
+	previous := len(existing.WatchedItems)
+	if float64(count) < float64(previous)*threshold {
+		return fmt.Errorf("the new backup has %d items, but the existing backup %s has %d. Use -allow-shrink to replace it anyway",
+			count, filename, previous)
 	}
+	return nil
+}
 
-	fmt.Printf("Restoring %d watched items for %s from backup created at %s\n",
-		len(backup.WatchedItems), client.GetConfig().UserName, backup.CreatedAt.Format(time.RFC3339))
-
-	// Group items by type
-	movies := make([]models.WatchedItem, 0)
-	tvShowMap := make(map[string]map[string][]models.WatchedItem)
+// sortWatchedItems sorts the items by type, series, season number, episode number and name
+func sortWatchedItems(items []models.WatchedItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.SeriesName != b.SeriesName {
+			return a.SeriesName < b.SeriesName
+		}
+		if seasonA, seasonB := numberOrZero(a.SeasonNumber), numberOrZero(b.SeasonNumber); seasonA != seasonB {
+			return seasonA < seasonB
+		}
+		if episodeA, episodeB := numberOrZero(a.EpisodeNumber), numberOrZero(b.EpisodeNumber); episodeA != episodeB {
+			return episodeA < episodeB
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.ID < b.ID
+	})
+}
 
-	for _, item := range backup.WatchedItems {
-		if item.Type == models.TypeMovie {
-			movies = append(movies, item)
-		} else if item.Type == models.TypeEpisode {
-			if tvShowMap[item.SeriesName] == nil {
-				tvShowMap[item.SeriesName] = make(map[string][]models.WatchedItem)
-			}
-			tvShowMap[item.SeriesName][item.SeasonName] = append(tvShowMap[item.SeriesName][item.SeasonName], item)
+// selectItems returns the watched items, and the items in progress with their resume
+// position if inProgress is set. See UserItem.IsWatched for the threshold
+func selectItems(userItems []jellyfin.UserItem, threshold float64, inProgress, favorites bool) []models.WatchedItem {
+	items := make([]models.WatchedItem, 0, len(userItems))
+	for _, userItem := range userItems {
+		item := userItem.Item
+		item.IsFavorite = favorites && userItem.IsFavorite
+		switch {
+		case userItem.IsWatched(threshold):
+			items = append(items, item)
+		case inProgress && userItem.InProgress():
+			item.PlaybackPositionTicks = userItem.PlaybackPositionTicks
+			item.PlayedPercentage = userItem.PlayedPercentage
+			items = append(items, item)
 		}
 	}
+	return items
+}
 
-	fmt.Printf("Found %d movies and %d TV shows\n", len(movies), len(tvShowMap))
+// countFavorites returns the number of favorites among the items
+func countFavorites(items []models.WatchedItem) int {
+	count := 0
+	for _, item := range items {
+		if item.IsFavorite {
+			count++
+		}
+	}
+	return count
+}
 
-	successful := 0
-	failed := 0
-	total := 0
-
-	// Process movies
-	if len(movies) > 0 {
-		fmt.Printf("\n=== Processing %d Movies ===\n", len(movies))
-		movieSuccess, movieFailed := restoreMovies(client, movies)
-		successful += movieSuccess
-		failed += movieFailed
-		total += len(movies)
-	}
-
-	// Process TV shows
-	if len(tvShowMap) > 0 {
-		fmt.Printf("\n=== Processing %d TV Shows ===\n", len(tvShowMap))
-		tvSuccess, tvFailed := restoreTVShows(client, tvShowMap)
-		successful += tvSuccess
-		failed += tvFailed
-		for _, seasons := range tvShowMap {
-			for _, episodes := range seasons {
-				total += len(episodes)
-			}
+// countUnselectedFavorites returns the number of favorites that are not among the selected
+// items, because they were neither watched nor in progress
+func countUnselectedFavorites(userItems []jellyfin.UserItem, selected []models.WatchedItem) int {
+	count := 0
+	for _, userItem := range userItems {
+		if userItem.IsFavorite {
+			count++
 		}
 	}
+	return count - countFavorites(selected)
+}
 
-	fmt.Printf("\n=== Restore Complete ===\n")
-	fmt.Printf("Successful: %d\n", successful)
-	fmt.Printf("Failed: %d\n", failed)
-	fmt.Printf("Total: %d\n", total)
+// countInProgress returns the number of items with a resume position
+func countInProgress(items []models.WatchedItem) int {
+	count := 0
+	for _, item := range items {
+		if item.PlaybackPositionTicks > 0 {
+			count++
+		}
+	}
+	return count
+}
 
-	return nil
+// countWithoutPlayedDate returns the number of items without a played date
+func countWithoutPlayedDate(items []models.WatchedItem) int {
+	count := 0
+	for _, item := range items {
+		if item.PlayedDate.IsZero() {
+			count++
+		}
+	}
+	return count
 }
 
-func restoreMovies(client *jellyfin.Client, movies []models.WatchedItem) (successful, failed int) {
-	providerIdMap, nameMap, err := client.GetAllMovies()
-	if err != nil {
-		fmt.Printf("Error fetching movies from server: %v\n", err)
-		return 0, len(movies)
+// numberOrZero returns the value of an optional number, 0 if it is not set
+func numberOrZero(number *int) int {
+	if number == nil {
+		return 0
 	}
+	return *number
+}
 
-	for i, movie := range movies {
-		fmt.Printf("[%d/%d] Processing movie: %s\n", i+1, len(movies), movie.Name)
+// filterByYear returns the items produced between yearFrom and yearTo, both inclusive,
+// and the number of excluded items. A limit of 0 is ignored. Items without a known year are excluded
+func filterByYear(items []models.WatchedItem, yearFrom, yearTo int) ([]models.WatchedItem, int) {
+	filtered := make([]models.WatchedItem, 0, len(items))
+	for _, item := range items {
+		if item.ProductionYear == 0 ||
+			(yearFrom != 0 && item.ProductionYear < yearFrom) ||
+			(yearTo != 0 && item.ProductionYear > yearTo) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+	return filtered, len(items) - len(filtered)
+}
 
-		var movieInfo jellyfin.MovieInfo
-		found := false
+// yearRange describes the years between yearFrom and yearTo for output
+func yearRange(yearFrom, yearTo int) string {
+	switch {
+	case yearTo == 0:
+		return fmt.Sprintf("%d and later", yearFrom)
+	case yearFrom == 0:
+		return fmt.Sprintf("%d and earlier", yearTo)
+	default:
+		return fmt.Sprintf("%d-%d", yearFrom, yearTo)
+	}
+}
 
-		// Try provider IDs first
-		for provider, id := range movie.ProviderIDs {
-			key := provider + ":" + id
-			if info, exists := providerIdMap[key]; exists {
-				movieInfo = info
-				found = true
-				break
-			}
-		}
+// mergeWithLastBackup adds all items that are not part of the existing backup yet and
+// updates items that were played again since it was created. Items that are no longer
+// watched on the server are kept, so the backup becomes a history. If there is no
+// backup yet, all items are returned
+func mergeWithLastBackup(client *jellyfin.Client, filename string, watchedItems []models.WatchedItem) ([]models.WatchedItem, error) {
+	lastBackup, err := loadBackup(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Println("No existing backup found, performing a full backup")
+		return watchedItems, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastBackup.ServerURL != client.GetConfig().ServerURL || lastBackup.UserID != client.GetConfig().UserID {
+		return nil, fmt.Errorf("existing backup %s was created for %s on %s", filename, lastBackup.UserName, lastBackup.ServerURL)
+	}
+	verifyChecksum(lastBackup)
 
-		// Fallback to name matching
-		if !found {
-			if info, exists := nameMap[movie.Name]; exists {
-				movieInfo = info
-				found = true
-			}
-		}
+	existing := make(map[string]int, len(lastBackup.WatchedItems))
+	for i, item := range lastBackup.WatchedItems {
+		existing[item.ID] = i
+	}
 
-		if !found {
-			fmt.Printf("  ✗ Could not find movie\n")
-			failed++
+	merged := lastBackup.WatchedItems
+	added, changed := 0, 0
+	for _, item := range watchedItems {
+		index, exists := existing[item.ID]
+		if !exists {
+			merged = append(merged, item)
+			added++
 			continue
 		}
-
-		// Skip if already watched
-		if movieInfo.Played {
-			fmt.Println("  ○ Already watched, skipping")
-			successful++
+		if item.PlayedDate.After(lastBackup.CreatedAt) {
+			merged[index] = item
 			continue
 		}
-
-		// Mark as watched
-		if err := client.MarkAsWatched(movieInfo.ID); err != nil {
-			fmt.Printf("  ✗ Failed to mark as watched: %v\n", err)
-			failed++
-			continue
+		// Items of older backups have no hash yet, so it is calculated from their content
+		oldHash := merged[index].ContentHash
+		if oldHash == "" {
+			if oldHash, err = merged[index].CalculateContentHash(); err != nil {
+				return nil, fmt.Errorf("calculating content hash: %w", err)
+			}
+		}
+		if oldHash != item.ContentHash {
+			merged[index] = item
+			changed++
 		}
-
-		fmt.Println("  ✓ Marked as watched")
-		successful++
 	}
 
-	return successful, failed
+	fmt.Printf("Added %d new items to the last backup from %s\n", added, lastBackup.CreatedAt.Format(time.RFC3339))
+	if changed > 0 {
+		fmt.Printf("Updated %d items whose metadata changed\n", changed)
+	}
+	return merged, nil
 }
 
-func restoreTVShows(client *jellyfin.Client, tvShowMap map[string]map[string][]models.WatchedItem) (successful, failed int) {
-	showCount := 0
-	for seriesName, seasons := range tvShowMap {
-		showCount++
-		episodeCount := 0
-		for _, episodes := range seasons {
-			episodeCount += len(episodes)
+// setContentHashes stores the content hash in every item
+func setContentHashes(items []models.WatchedItem) error {
+	for i := range items {
+		hash, err := items[i].CalculateContentHash()
+		if err != nil {
+			return fmt.Errorf("calculating content hash: %w", err)
 		}
+		items[i].ContentHash = hash
+	}
+	return nil
+}
 
-		fmt.Printf("\n[%d/%d] Processing show: %s (%d episodes)\n", showCount, len(tvShowMap), seriesName, episodeCount)
+// verifyChecksum warns if the watched items of a backup do not match the stored checksum
+func verifyChecksum(backup models.Backup) {
+	if backup.Checksum == "" {
+		fmt.Println("⚠ Backup has no checksum, skipping integrity check")
+		return
+	}
+	checksum, err := backup.CalculateChecksum()
+	if err != nil {
+		fmt.Printf("⚠ Could not calculate checksum: %v\n", err)
+		return
+	}
+	if checksum != backup.Checksum {
+		fmt.Println("⚠ Checksum mismatch: the backup file has been modified or is corrupted")
+	}
+}
 
-		// Find the series ID
-		seriesID, err := client.FindSeriesID(seriesName)
-		if err != nil {
-			fmt.Printf("  ✗ Error finding series: %v\n", err)
-			for _, episodes := range seasons {
-				failed += len(episodes)
-			}
-			continue
+// printBackupSummary prints statistics about the items that would be stored in a backup
+func printBackupSummary(watchedItems []models.WatchedItem) {
+	var movies, episodes, unknown, withoutProviderIDs int
+	var oldest, newest time.Time
+	series := make(map[string]bool)
+
+	for _, item := range watchedItems {
+		switch item.Type {
+		case models.TypeMovie:
+			movies++
+		case models.TypeEpisode:
+			episodes++
+			series[item.SeriesName] = true
+		default:
+			unknown++
 		}
 
-		// Fetch all episodes for this series
-		episodes, err := client.GetEpisodesForSeries(seriesID)
-		if err != nil {
-			fmt.Printf("  ✗ Error fetching episodes: %v\n", err)
-			for _, eps := range seasons {
-				failed += len(eps)
-			}
-			continue
+		if len(item.ProviderIDs) == 0 {
+			withoutProviderIDs++
 		}
 
-		// Build lookup maps
-		type EpisodeInfo struct {
-			ID     string
-			Played bool
+		if item.PlayedDate.IsZero() {
+			continue
 		}
-		providerIdMap := make(map[string]EpisodeInfo)
-		nameSeasonMap := make(map[string]EpisodeInfo)
-
-		for _, ep := range episodes {
-			info := EpisodeInfo{
-				ID:     ep.ID,
-				Played: ep.Played,
-			}
-
-			key := ep.SeasonName + ":" + ep.Name
-			nameSeasonMap[key] = info
-
-			for provider, id := range ep.ProviderIDs {
-				providerKey := provider + ":" + id
-				providerIdMap[providerKey] = info
-			}
+		if oldest.IsZero() || item.PlayedDate.Before(oldest) {
+			oldest = item.PlayedDate
+		}
+		if newest.IsZero() || item.PlayedDate.After(newest) {
+			newest = item.PlayedDate
 		}
+	}
 
-		// Process each season
-		for seasonName, seasonEpisodes := range seasons {
-			fmt.Printf("  Season: %s (%d episodes)\n", seasonName, len(seasonEpisodes))
+	fmt.Printf("\n=== Backup Preview ===\n")
+	fmt.Printf("Total items: %d\n", len(watchedItems))
+	fmt.Printf("Movies: %d\n", movies)
+	fmt.Printf("Episodes: %d (from %d series)\n", episodes, len(series))
+	if unknown > 0 {
+		fmt.Printf("Unknown type: %d\n", unknown)
+	}
+	if oldest.IsZero() {
+		fmt.Println("Played dates: none recorded")
+	} else {
+		fmt.Printf("Played dates: %s to %s\n", oldest.Format(time.DateOnly), newest.Format(time.DateOnly))
+	}
+	fmt.Printf("Items without provider IDs: %d\n", withoutProviderIDs)
+	if withoutProviderIDs > 0 {
+		fmt.Println("  ⚠ These items can only be restored by name matching")
+	}
+}
 
-			for _, episode := range seasonEpisodes {
-				var episodeInfo EpisodeInfo
-				found := false
+// restoreOptions holds the settings for restoring a backup
+type restoreOptions struct {
+	// RetryUnmatched enables a second pass with relaxed matching for items that were not found
+	RetryUnmatched bool
+	// SkipWatchedSeries skips series without unwatched episodes on the server
+	SkipWatchedSeries bool
+	// DiffOnly lists the items that would be marked as watched and asks for confirmation first
+	DiffOnly bool
+	// AssumeYes applies the changes of DiffOnly without asking
+	AssumeYes bool
+	// Pending collects the matched items instead of marking them as watched, if set
+	Pending *pendingChanges
+	// MatchOnly only finds the items in the library for OnMatch, without comparing or changing
+	// their watched state, e.g. for operations that change them differently
+	MatchOnly bool
+	// Favorites collects the matched favorites, which are then marked by the caller instead of
+	// at the end of the restore, if set
+	Favorites *favoriteChanges
+	// ClearSourceAfter marks the migrated items as unwatched on the source server
+	// after they were all restored
+	ClearSourceAfter bool
+	// ExactSeriesOnly fails series whose name has no exact match instead of using the first search result
+	ExactSeriesOnly bool
+	// NoNameMatch fails items without a matching provider ID instead of matching them by name
+	NoNameMatch bool
+	// MatchPaths matches items without a matching provider ID by their file path before trying the name
+	MatchPaths bool
+	// CheckProviderNames reports inconsistent provider names and malformed IDs before restoring
+	CheckProviderNames bool
+	// RenameSeries replaces series names of the backup before the series are searched
+	RenameSeries seriesMap
+	// InProgress also migrates items that were started but not finished, with their resume position
+	InProgress bool
+	// IncludeFavorites also migrates which of the items are favorites
+	IncludeFavorites bool
+	// Order is orderOldestFirst or orderNewestFirst
+	Order string
+	// DryRun only matches the items and lists those that would be marked as watched
+	DryRun bool
+	// Chunks pauses between chunks of marked items, if set
+	Chunks *chunker
+	// OnMatch is called for every item that was found in the library, if set
+	OnMatch func(item models.WatchedItem, info libraryItem)
+}
 
-				// Try provider IDs first
-				for provider, id := range episode.ProviderIDs {
-					key := provider + ":" + id
-					if info, exists := providerIdMap[key]; exists {
-						episodeInfo = info
-						found = true
-						break
-					}
-				}
+func performRestore(client *jellyfin.Client, filename string, options restoreOptions) error {
+	backup, err := loadBackup(filename)
+	if err != nil {
+		return err
+	}
+	verifyChecksum(backup)
+	if options.CheckProviderNames {
+		checkProviderNames(backup.WatchedItems).print()
+	}
 
-				// Fallback to season + name matching
-				if !found {
-					key := episode.SeasonName + ":" + episode.Name
-					if info, exists := nameSeasonMap[key]; exists {
-						episodeInfo = info
-						found = true
-					}
-				}
+	fmt.Printf("Restoring %d watched items for %s from backup created at %s\n",
+		len(backup.WatchedItems), client.GetConfig().UserName, backup.CreatedAt.Format(time.RFC3339))
+	switch {
+	case options.DryRun:
+		restoreDryRun(client, backup.WatchedItems, options)
+	case options.DiffOnly:
+		restoreWithReview(client, backup.WatchedItems, options)
+	default:
+		restoreItems(client, backup.WatchedItems, options)
+	}
+	if deadlineReached(client) {
+		return errors.New("deadline reached before all items were restored")
+	}
+	if client.WriteDenied() {
+		return jellyfin.ErrWriteDenied
+	}
+	return nil
+}
 
-				if !found {
-					fmt.Printf("    ✗ %s - not found\n", episode.Name)
-					failed++
-					continue
-				}
+// performMigrate restores the watched items of a user on the source server directly,
+// without writing a backup file
+func performMigrate(source, client *jellyfin.Client, options restoreOptions) error {
+	sourceConfig := source.GetConfig()
+	fmt.Printf("Fetching watched items from %s for user %s...\n", sourceConfig.ServerURL, sourceConfig.UserName)
+	userItems, err := source.GetUserItems()
+	if err != nil {
+		return fmt.Errorf("fetching watched items from source server: %w", err)
+	}
+	items := selectItems(userItems, 0, options.InProgress, options.IncludeFavorites)
+	if options.CheckProviderNames {
+		checkProviderNames(items).print()
+	}
 
-				// Skip if already watched
-				if episodeInfo.Played {
-					successful++
-					continue
-				}
+	fmt.Printf("Restoring %d watched items for %s from %s\n", len(items), client.GetConfig().UserName, sourceConfig.ServerURL)
+	var complete bool
+	switch {
+	case options.DryRun:
+		restoreDryRun(client, items, options)
+		// The source is not cleared, as nothing was restored
+		return nil
+	case options.DiffOnly:
+		complete = restoreWithReview(client, items, options)
+	default:
+		complete = restoreItems(client, items, options)
+	}
 
-				// Mark as watched
-				if err := client.MarkAsWatched(episodeInfo.ID); err != nil {
-					fmt.Printf("    ✗ %s - failed to mark: %v\n", episode.Name, err)
-					failed++
-					continue
-				}
+	if deadlineReached(client) {
+		return errors.New("deadline reached before all items were restored")
+	}
+	if client.WriteDenied() {
+		return jellyfin.ErrWriteDenied
+	}
+	if !options.ClearSourceAfter {
+		return nil
+	}
+	if !complete {
+		return fmt.Errorf("not clearing the watched state on %s, as not all items were restored", sourceConfig.ServerURL)
+	}
+	return clearSource(source, items, options.AssumeYes)
+}
 
-				successful++
-			}
+// clearSource removes the watched state of the migrated items on the source server
+// after asking for confirmation, unless assumeYes is set
+func clearSource(source *jellyfin.Client, items []models.WatchedItem, assumeYes bool) error {
+	sourceConfig := source.GetConfig()
+	question := fmt.Sprintf("Mark %d items as unwatched on %s for user %s? This cannot be undone",
+		len(items), sourceConfig.ServerURL, sourceConfig.UserName)
+	if !assumeYes && !confirm(question) {
+		fmt.Println("The watched state on the source server was kept")
+		return nil
+	}
 
-			fmt.Printf("    ✓ Processed %d episodes\n", len(seasonEpisodes))
+	fmt.Printf("\n=== Clearing %d Items On %s ===\n", len(items), sourceConfig.ServerURL)
+	failed := 0
+	for _, item := range items {
+		if err := source.MarkAsUnwatched(item.ID); err != nil {
+			fmt.Printf("  ✗ %s - failed to mark as unwatched: %v\n", itemDisplayName(item), err)
+			failed++
 		}
 	}
-
-	return successful, failed
+	fmt.Printf("Marked %d of %d items as unwatched on the source server\n", len(items)-failed, len(items))
+	if failed > 0 {
+		return fmt.Errorf("%d items could not be marked as unwatched on the source server", failed)
+	}
+	return nil
 }
 
-func performFindMissing(jellyfinClient *jellyfin.Client, tvdbAPIKey string, includeSpecials bool) error {
-	fmt.Println("Initializing TVDB client...")
-	tvdbClient := tvdb.NewClient(tvdbAPIKey)
-
-	if err := tvdbClient.Login(); err != nil {
-		return fmt.Errorf("TVDB login failed: %w", err)
+// restoreItems marks the given items as watched and prints a summary.
+// It returns true if all items were restored
+func restoreItems(client *jellyfin.Client, items []models.WatchedItem, options restoreOptions) bool {
+	// Favorites are only restored with the watched state, not when comparing for other operations
+	favorites := options.Favorites
+	if favorites == nil && options.Pending == nil && !options.MatchOnly {
+		favorites = &favoriteChanges{}
+	}
+	if favorites != nil {
+		options = favorites.collect(options)
 	}
-	fmt.Println("✓ TVDB authentication successful")
 
-	fmt.Println("\nFetching all series from Jellyfin...")
-	series, err := jellyfinClient.GetAllSeries()
-	if err != nil {
-		return fmt.Errorf("fetching Jellyfin series: %w", err)
+	// All items are restored in the order they were played, whether they are movies or episodes
+	items = sortByPlayedDate(items, options.Order)
+
+	movies, episodes := 0, 0
+	series := make(map[string]bool)
+	for _, item := range items {
+		switch item.Type {
+		case models.TypeMovie:
+			movies++
+		case models.TypeEpisode:
+			episodes++
+			series[options.RenameSeries.canonical(item.SeriesName)] = true
+		}
 	}
-	fmt.Printf("✓ Found %d series in Jellyfin\n", len(series))
-	fmt.Println("Checking for missing episodes...")
-	totalMissing := 0
+	fmt.Printf("Found %d movies and %d TV shows\n", movies, len(series))
 
-	for i, s := range series {
-		// Check if series has TVDB ID
-		tvdbID, hasTVDB := s.ProviderIDs["Tvdb"]
-		if !hasTVDB {
+	successful := 0
+	failed := 0
+	nameMatches := 0
+	total := movies + episodes
+	var unmatched []models.WatchedItem
+
+	fmt.Printf("\n=== Processing %d Items ===\n", total)
+	library := newRestoreLibrary(client, options)
+	processed := 0
+	for _, item := range items {
+		if item.Type != models.TypeMovie && item.Type != models.TypeEpisode {
 			continue
 		}
+		if restoreStopped(client) {
+			break
+		}
+		processed++
+		if item.Type == models.TypeEpisode {
+			fmt.Printf("[%d/%d] Processing episode: %s\n", processed, total, itemDisplayName(item))
+		} else {
+			fmt.Printf("[%d/%d] Processing movie: %s\n", processed, total, item.Name)
+		}
 
-		// Get episodes from TVDB
-		tvdbEpisodes, err := tvdbClient.GetSeriesEpisodes(tvdbID)
+		info, match, err := library.match(item)
 		if err != nil {
-			fmt.Printf("\n[%d/%d] %s (TVDB: %s)\n", i+1, len(series), s.Name, tvdbID)
-			fmt.Printf("  ⚠ Could not fetch TVDB episodes: %v\n", err)
+			fmt.Printf("  ✗ %v\n", err)
+			failed++
+			unmatched = append(unmatched, item)
+			continue
+		}
+		switch match {
+		case matchNotFound:
+			if item.Type == models.TypeEpisode {
+				fmt.Println("  ✗ Could not find episode")
+			} else {
+				fmt.Println("  ✗ Could not find movie")
+			}
+			failed++
+			unmatched = append(unmatched, item)
+			continue
+		case matchSeriesWatched:
+			fmt.Println("  ○ All episodes of the series already watched, skipping")
+			successful++
+			continue
+		case matchByName:
+			nameMatches++
+			fmt.Println("  ⚠ Low-confidence name match")
+		}
+		if options.OnMatch != nil {
+			options.OnMatch(item, info)
+		}
+		if options.MatchOnly {
+			successful++
 			continue
 		}
 
-		// Get episodes from Jellyfin
-		jellyfinEpisodes, err := jellyfinClient.GetEpisodesForSeries(s.ID)
-		if err != nil {
-			fmt.Printf("\n[%d/%d] %s (TVDB: %s)\n", i+1, len(series), s.Name, tvdbID)
-			fmt.Printf("  ⚠ Could not fetch Jellyfin episodes: %v\n", err)
+		// Skip if already watched
+		if info.Played {
+			fmt.Println("  ○ Already watched, skipping")
+			successful++
 			continue
 		}
 
-		// Build map of existing episodes and store runtime seconds
-		// The runtime is required to check if two multi-part episodes have been merged
-		existingEpisodes := make(map[string]int)
-		for _, ep := range jellyfinEpisodes {
-			key := fmt.Sprintf("%d:%d", ep.SeasonNumber, ep.EpisodeNumber)
-			existingEpisodes[key] = ep.RuntimeMinutes
+		// Mark as watched, or set the resume position
+		if err := markAsWatched(client, options, item, info); err != nil {
+			fmt.Printf("  ✗ Failed to mark as watched: %v\n", err)
+			failed++
+			continue
 		}
 
-		// Find missing episodes
-		missing := tvdb.FindMissingEpisodes(tvdbEpisodes, existingEpisodes, includeSpecials)
+		if options.Pending != nil {
+			fmt.Println("  + Not watched yet")
+		} else if item.PlaybackPositionTicks > 0 {
+			fmt.Printf("  ✓ Resume position set to %s\n", formatPosition(item.PlaybackPositionTicks))
+		} else {
+			fmt.Println("  ✓ Marked as watched")
+		}
+		successful++
+	}
 
-		if len(missing) != 0 {
-			fmt.Printf("\n[%d/%d] %s (TVDB: %s)\n", i+1, len(series), s.Name, tvdbID)
-			fmt.Printf("  ⚠ Missing %d episodes (of %d total):\n", len(missing), len(tvdbEpisodes))
-			for _, m := range missing {
-				fmt.Printf("    - S%02dE%02d: %s (Aired: %s)\n",
-					m.SeasonNumber, m.EpisodeNumber, m.EpisodeName, m.AirDate)
-			}
-			totalMissing += len(missing)
+	if options.RetryUnmatched && len(unmatched) > 0 && !restoreStopped(client) {
+		fmt.Printf("\n=== Retrying %d Unmatched Items ===\n", len(unmatched))
+		recovered := retryUnmatched(client, unmatched, options)
+		successful += recovered
+		failed -= recovered
+		fmt.Printf("Recovered %d of %d unmatched items\n", recovered, len(unmatched))
+	}
+	if options.MatchOnly {
+		return printMatchSummary(client, successful, failed, nameMatches, total)
+	}
+	favoritesFailed := 0
+	if options.Favorites == nil && options.Pending == nil {
+		favoritesFailed = favorites.apply(client)
+	}
+
+	switch {
+	case options.DryRun:
+		fmt.Printf("\n=== Dry Run Complete ===\n")
+	case options.Pending != nil:
+		fmt.Printf("\n=== Comparison Complete ===\n")
+	default:
+		fmt.Printf("\n=== Restore Complete ===\n")
+	}
+	if options.DryRun {
+		fmt.Printf("Would mark as watched: %d\n", len(options.Pending.items))
+		fmt.Printf("Already watched: %d\n", successful-len(options.Pending.items))
+		recordCount("would_mark", len(options.Pending.items))
+		if count := options.Favorites.count(); count > 0 {
+			fmt.Printf("Would mark as favorite: %d\n", count)
 		}
+	} else {
+		fmt.Printf("Successful: %d\n", successful)
+	}
+	fmt.Printf("Failed: %d\n", failed)
+	recordCount("successful", successful)
+	recordCount("failed", failed)
+	recordCount("name_matches", nameMatches)
+	recordCount("total", total)
+	if nameMatches > 0 {
+		fmt.Printf("⚠ Matched by name only: %d (low confidence)\n", nameMatches)
+	}
+	fmt.Printf("Total: %d\n", total)
+	if deadlineReached(client) {
+		fmt.Printf("⚠ Deadline reached, %d items were not processed\n", total-successful-failed)
+		return false
+	}
+	if client.WriteDenied() {
+		fmt.Printf("✗ The restore was stopped because the %v\n", jellyfin.ErrWriteDenied)
+		if remaining := total - successful - failed; remaining > 0 {
+			fmt.Printf("  %d items were not processed\n", remaining)
+		}
+		return false
+	}
+	return failed == 0 && favoritesFailed == 0
+}
 
+// printMatchSummary prints the result of restoreItems with MatchOnly and returns true if all
+// items were found
+func printMatchSummary(client *jellyfin.Client, matched, notFound, nameMatches, total int) bool {
+	fmt.Printf("\n=== Matching Complete ===\n")
+	fmt.Printf("Found in the library: %d\n", matched)
+	fmt.Printf("Not found: %d\n", notFound)
+	recordCount("matched", matched)
+	recordCount("not_found", notFound)
+	recordCount("name_matches", nameMatches)
+	recordCount("total", total)
+	if nameMatches > 0 {
+		fmt.Printf("⚠ Matched by name only: %d (low confidence)\n", nameMatches)
 	}
+	fmt.Printf("Total: %d\n", total)
+	if deadlineReached(client) {
+		fmt.Printf("⚠ Deadline reached, %d items were not processed\n", total-matched-notFound)
+		return false
+	}
+	return notFound == 0
+}
 
-	fmt.Printf("\n=== Summary ===\n")
-	fmt.Printf("Total series checked: %d\n", len(series))
-	fmt.Printf("Total missing episodes: %d\n", totalMissing)
+// deadlineReached returns true if the -deadline of the run has passed
+func deadlineReached(client *jellyfin.Client) bool {
+	return errors.Is(client.Context().Err(), context.DeadlineExceeded)
+}
 
-	return nil
+// restoreStopped returns true if no further items can be restored, because the deadline
+// has passed or the server does not allow changes with this API key
+func restoreStopped(client *jellyfin.Client) bool {
+	return deadlineReached(client) || client.WriteDenied()
 }
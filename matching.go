@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// libraryItem is an item on the server that entries of a backup are matched against
+type libraryItem struct {
+	ID     string
+	Name   string
+	Played bool
+}
+
+// providerIndex maps provider keys to all library items that carry this provider ID
+type providerIndex map[string][]libraryItem
+
+// add stores the item for all of its provider IDs. A warning is printed if a
+// provider ID is already used by a different item, as this usually indicates
+// wrong metadata on the server
+func (p providerIndex) add(providerIDs map[string]string, item libraryItem) {
+	for provider, id := range providerIDs {
+		if id == "" {
+			continue
+		}
+		key := models.ProviderKey(provider, id)
+		if p.contains(key, item.ID) {
+			continue
+		}
+		if len(p[key]) == 1 {
+			fmt.Printf("⚠ Provider ID %s is used by multiple items: \"%s\" and \"%s\"\n", key, p[key][0].Name, item.Name)
+		}
+		p[key] = append(p[key], item)
+	}
+}
+
+// contains returns true if the item with the given ID is already stored for the key
+func (p providerIndex) contains(key, itemID string) bool {
+	for _, existing := range p[key] {
+		if existing.ID == itemID {
+			return true
+		}
+	}
+	return false
+}
+
+// find returns the library item matching one of the given provider IDs.
+// Providers are checked in the order of orderedProviderKeys, so the result is the same on every run.
+// If multiple items share a provider ID, the item with the same name is preferred,
+// otherwise the item with the lowest ID is chosen
+func (p providerIndex) find(providerIDs map[string]string, name string) (libraryItem, bool) {
+	for _, key := range orderedProviderKeys(providerIDs, models.ProviderKey) {
+		candidates := p[key]
+		if len(candidates) == 0 {
+			continue
+		}
+		if len(candidates) == 1 {
+			return candidates[0], true
+		}
+
+		var nameMatches []libraryItem
+		for _, candidate := range candidates {
+			if strings.EqualFold(candidate.Name, name) {
+				nameMatches = append(nameMatches, candidate)
+			}
+		}
+		if len(nameMatches) == 0 {
+			fmt.Printf("  ⚠ Provider ID %s is ambiguous and no item is named \"%s\"\n", key, name)
+			nameMatches = candidates
+		}
+		return lowestID(nameMatches), true
+	}
+	return libraryItem{}, false
+}
+
+// orderedProviderKeys returns the keys of the provider IDs in the order they are tried when
+// matching: providers with a priority from the config file first, the others alphabetically
+func orderedProviderKeys(providerIDs map[string]string, key func(provider, id string) string) []string {
+	type rankedKey struct {
+		rank int
+		key  string
+	}
+	ranked := make([]rankedKey, 0, len(providerIDs))
+	for provider, id := range providerIDs {
+		ranked = append(ranked, rankedKey{rank: models.ProviderRank(provider), key: key(provider, id)})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].rank != ranked[j].rank {
+			return ranked[i].rank < ranked[j].rank
+		}
+		return ranked[i].key < ranked[j].key
+	})
+	keys := make([]string, len(ranked))
+	for i, r := range ranked {
+		keys[i] = r.key
+	}
+	return keys
+}
+
+// lowestID returns the item with the lowest ID
+func lowestID(items []libraryItem) libraryItem {
+	result := items[0]
+	for _, item := range items[1:] {
+		if item.ID < result.ID {
+			result = item
+		}
+	}
+	return result
+}
+
+// normalizePath makes file paths comparable across servers. Backslashes of Windows paths are
+// converted, duplicate separators removed and the path is converted to lower case
+func normalizePath(filePath string) string {
+	filePath = strings.TrimSpace(strings.ReplaceAll(filePath, `\`, "/"))
+	if filePath == "" {
+		return ""
+	}
+	return strings.ToLower(path.Clean(filePath))
+}
+
+// pathIndex maps normalised file paths to library items
+type pathIndex map[string]libraryItem
+
+// add stores the item for its file path. Items without a path are ignored
+func (p pathIndex) add(filePath string, item libraryItem) {
+	if key := normalizePath(filePath); key != "" {
+		p[key] = item
+	}
+}
+
+// find returns the library item with the same file path
+func (p pathIndex) find(filePath string) (libraryItem, bool) {
+	key := normalizePath(filePath)
+	if key == "" {
+		return libraryItem{}, false
+	}
+	item, found := p[key]
+	return item, found
+}
@@ -1,16 +1,64 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
 
 // WatchedItem represents a watched movie or episode
 type WatchedItem struct {
-	ID          string            `json:"id"`
-	Name        string            `json:"name"`
-	Type        int               `json:"type"`
-	SeriesName  string            `json:"series_name,omitempty"`
-	SeasonName  string            `json:"season_name,omitempty"`
-	PlayedDate  time.Time         `json:"played_date"`
-	ProviderIDs map[string]string `json:"provider_ids,omitempty"`
+	ID         string `json:"id" xml:"id"`
+	Name       string `json:"name" xml:"name"`
+	Type       int    `json:"type" xml:"type"`
+	SeriesName string `json:"series_name,omitempty" xml:"series_name,omitempty"`
+	SeasonName string `json:"season_name,omitempty" xml:"season_name,omitempty"`
+	// SeasonNumber is nil for movies and for backups created by older versions
+	SeasonNumber *int `json:"season_number,omitempty" xml:"season_number,omitempty"`
+	// EpisodeNumber is nil for movies and for backups created by older versions
+	EpisodeNumber *int        `json:"episode_number,omitempty" xml:"episode_number,omitempty"`
+	PlayedDate    time.Time   `json:"played_date" xml:"played_date"`
+	ProviderIDs   ProviderIDs `json:"provider_ids,omitempty" xml:"provider_ids,omitempty"`
+	// ProductionYear is 0 if unknown or for backups created by older versions
+	ProductionYear int `json:"production_year,omitempty" xml:"production_year,omitempty"`
+	// DateAdded is when the item was added to the library. It is informational only and
+	// ignored on restore and nil if unknown, which keeps the checksum of older backups unchanged.
+	// It is a pointer, as encoding/xml does not omit zero structs
+	DateAdded *time.Time `json:"date_added,omitempty" xml:"date_added,omitempty"`
+	// PrimaryImageTag is the tag of the primary image, only stored with -include-images.
+	// The image is available at /Items/{id}/Images/Primary?tag={tag}
+	PrimaryImageTag string `json:"primary_image_tag,omitempty" xml:"primary_image_tag,omitempty"`
+	// Path is the file path of the item on the server. It is empty for backups created by older versions
+	Path string `json:"path,omitempty" xml:"path,omitempty"`
+	// PlaybackPositionTicks is the resume position of an item that was started but not finished.
+	// It is 0 for watched items, which are marked as watched on restore
+	PlaybackPositionTicks int64 `json:"playback_position_ticks,omitempty" xml:"playback_position_ticks,omitempty"`
+	// PlayedPercentage is how much of an item in progress was played, from 0 to 100. It is
+	// informational only, the resume position is restored from PlaybackPositionTicks
+	PlayedPercentage float64 `json:"played_percentage,omitempty" xml:"played_percentage,omitempty"`
+	// IsFavorite is set for favorites if the backup was created with -include-favorites
+	IsFavorite bool `json:"is_favorite,omitempty" xml:"is_favorite,omitempty"`
+	// ContentHash identifies the content of all other fields, see CalculateContentHash.
+	// It is empty for backups created by older versions
+	ContentHash string `json:"content_hash,omitempty" xml:"content_hash,omitempty"`
+}
+
+// contentHashLength is the number of hex digits of a content hash, which is enough to
+// detect changes of a single item
+const contentHashLength = 16
+
+// CalculateContentHash returns a hash of all fields except ContentHash, so changed metadata
+// of an item can be detected between backups, e.g. a provider ID that was added
+func (i WatchedItem) CalculateContentHash() (string, error) {
+	i.ContentHash = ""
+	data, err := json.Marshal(i)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])[:contentHashLength], nil
 }
 
 const (
@@ -24,28 +72,54 @@ const (
 
 // Backup holds all watched items
 type Backup struct {
-	CreatedAt    time.Time     `json:"created_at"`
-	ServerURL    string        `json:"server_url"`
-	UserID       string        `json:"user_id"`
-	UserName     string        `json:"user_name"`
-	AppVersion   string        `json:"version"`
-	WatchedItems []WatchedItem `json:"watched_items"`
+	XMLName      xml.Name      `json:"-" xml:"backup"`
+	CreatedAt    time.Time     `json:"created_at" xml:"created_at"`
+	ServerURL    string        `json:"server_url" xml:"server_url"`
+	UserID       string        `json:"user_id" xml:"user_id"`
+	UserName     string        `json:"user_name" xml:"user_name"`
+	AppVersion   string        `json:"version" xml:"version"`
+	Checksum     string        `json:"checksum,omitempty" xml:"checksum,omitempty"`
+	WatchedItems []WatchedItem `json:"watched_items" xml:"watched_items>item"`
+}
+
+// CalculateChecksum returns the SHA-256 hash of the watched items.
+// The items are serialised with encoding/json, which writes struct fields in
+// declaration order and map keys sorted, so the result is reproducible
+func (b Backup) CalculateChecksum() (string, error) {
+	data, err := json.Marshal(b.WatchedItems)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
 }
 
 // Config holds connection settings
 type Config struct {
-	ServerURL string
-	APIKey    string
-	UserID    string
-	UserName  string
+	ServerURL     string
+	APIKey        string
+	UserID        string
+	UserName      string
+	ServerVersion string
 }
 
 // MissingEpisode represents an episode that exists in TVDB but not in Jellyfin
 type MissingEpisode struct {
-	SeriesName    string
-	SeasonNumber  int
-	EpisodeNumber int
-	EpisodeName   string
-	AirDate       string
-	Overview      string
+	// TvdbID is the TVDB ID of the episode, 0 if unknown
+	TvdbID        int    `json:"tvdb_id,omitempty"`
+	SeriesName    string `json:"series_name,omitempty"`
+	SeasonNumber  int    `json:"season_number"`
+	EpisodeNumber int    `json:"episode_number"`
+	EpisodeName   string `json:"episode_name"`
+	AirDate       string `json:"air_date"`
+	Overview      string `json:"overview,omitempty"`
+	// RuntimeMinutes is the runtime listed on TVDB, 0 if unknown
+	RuntimeMinutes int `json:"runtime_minutes,omitempty"`
+}
+
+// SeriesError represents a series that could not be checked for missing episodes
+type SeriesError struct {
+	SeriesName string `json:"series_name"`
+	TvdbID     string `json:"tvdb_id"`
+	Reason     string `json:"reason"`
 }
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+// ProviderIDs maps provider names to the ID of an item at that provider
+type ProviderIDs map[string]string
+
+// xmlProviderID is the XML representation of a single provider ID
+type xmlProviderID struct {
+	Provider string `xml:"provider,attr"`
+	ID       string `xml:",chardata"`
+}
+
+// MarshalXML writes the provider IDs as a list of elements sorted by provider,
+// as encoding/xml does not support maps
+func (p ProviderIDs) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	providers := make([]string, 0, len(p))
+	for provider := range p {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	list := struct {
+		IDs []xmlProviderID `xml:"id"`
+	}{}
+	for _, provider := range providers {
+		list.IDs = append(list.IDs, xmlProviderID{Provider: provider, ID: p[provider]})
+	}
+	return e.EncodeElement(list, start)
+}
+
+// UnmarshalXML reads provider IDs written by MarshalXML
+func (p *ProviderIDs) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var list struct {
+		IDs []xmlProviderID `xml:"id"`
+	}
+	if err := d.DecodeElement(&list, &start); err != nil {
+		return err
+	}
+	*p = make(ProviderIDs, len(list.IDs))
+	for _, id := range list.IDs {
+		(*p)[id.Provider] = id.ID
+	}
+	return nil
+}
+
+// Provider names as reported by Jellyfin
+const (
+	ProviderImdb    = "Imdb"
+	ProviderTmdb    = "Tmdb"
+	ProviderTvdb    = "Tvdb"
+	ProviderTvMaze  = "TvMaze"
+	ProviderAniDB   = "AniDB"
+	ProviderAniList = "AniList"
+)
+
+// canonicalProviders maps lower-case provider names to their canonical spelling.
+// Anime plugins and older server versions do not always use the same casing,
+// e.g. "AniDb" or "Anilist", which would otherwise prevent matches across servers
+var canonicalProviders = map[string]string{
+	"imdb":    ProviderImdb,
+	"tmdb":    ProviderTmdb,
+	"tvdb":    ProviderTvdb,
+	"tvmaze":  ProviderTvMaze,
+	"anidb":   ProviderAniDB,
+	"anilist": ProviderAniList,
+}
+
+// RegisterProvider adds a provider that is not known by default, e.g. one added by a plugin,
+// together with other spellings of its name. It must be called before any items are matched
+func RegisterProvider(name string, aliases ...string) {
+	canonicalProviders[strings.ToLower(strings.TrimSpace(name))] = name
+	for _, alias := range aliases {
+		canonicalProviders[strings.ToLower(strings.TrimSpace(alias))] = name
+	}
+}
+
+// providerPriority maps canonical provider names to their position in the priority list
+var providerPriority = map[string]int{}
+
+// SetProviderPriority sets the providers whose IDs are tried first when matching, in this order.
+// It must be called before any items are matched
+func SetProviderPriority(providers []string) {
+	providerPriority = make(map[string]int, len(providers))
+	for i, provider := range providers {
+		provider = NormalizeProviderName(provider)
+		if _, exists := providerPriority[provider]; !exists {
+			providerPriority[provider] = i
+		}
+	}
+}
+
+// ProviderRank returns the position of the provider in the priority list. Providers
+// without a priority share the position after the last one
+func ProviderRank(provider string) int {
+	if rank, found := providerPriority[NormalizeProviderName(provider)]; found {
+		return rank
+	}
+	return len(providerPriority)
+}
+
+// NormalizeProviderName returns the canonical spelling of a provider name.
+// Unknown providers are returned unchanged
+func NormalizeProviderName(provider string) string {
+	canonical, ok := canonicalProviders[strings.ToLower(strings.TrimSpace(provider))]
+	if !ok {
+		return provider
+	}
+	return canonical
+}
+
+// ProviderKey returns the key that is used to match items by their provider ID
+func ProviderKey(provider, id string) string {
+	return NormalizeProviderName(provider) + ":" + strings.TrimSpace(id)
+}
+
+// GetProviderID returns the ID for the given provider, regardless of the casing used by the server
+func GetProviderID(providerIDs map[string]string, provider string) (string, bool) {
+	provider = NormalizeProviderName(provider)
+	for name, id := range providerIDs {
+		if NormalizeProviderName(name) == provider && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
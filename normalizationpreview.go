@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// rawProviderKey is the key of a provider ID without normalising the provider name or the ID,
+// as items were matched before the normalisation was added
+func rawProviderKey(provider, id string) string {
+	return provider + ":" + id
+}
+
+// previewIndex maps the provider keys of the library to its items, separately for each type.
+// Unlike providerIndex, it does not print warnings, so both variants can be built quietly
+type previewIndex struct {
+	key   func(provider, id string) string
+	items map[string][]libraryItem
+}
+
+// newPreviewIndex stores all items of the library with the given key function
+func newPreviewIndex(library []jellyfin.UserItem, key func(provider, id string) string) previewIndex {
+	index := previewIndex{key: key, items: make(map[string][]libraryItem)}
+	for _, userItem := range library {
+		info := libraryItem{ID: userItem.Item.ID, Name: userItem.Item.Name, Played: userItem.Played}
+		for provider, id := range userItem.Item.ProviderIDs {
+			if strings.TrimSpace(id) == "" {
+				continue
+			}
+			typedKey := index.typedKey(userItem.Item.Type, provider, id)
+			index.items[typedKey] = append(index.items[typedKey], info)
+		}
+	}
+	return index
+}
+
+// typedKey prefixes the key with the type, as movies are only matched with movies and episodes with episodes
+func (p previewIndex) typedKey(itemType int, provider, id string) string {
+	return fmt.Sprintf("%d/%s", itemType, p.key(provider, id))
+}
+
+// find returns the library item for the provider IDs like providerIndex.find
+func (p previewIndex) find(item models.WatchedItem) (libraryItem, bool) {
+	keys := orderedProviderKeys(item.ProviderIDs, func(provider, id string) string {
+		return p.typedKey(item.Type, provider, id)
+	})
+	for _, key := range keys {
+		candidates := p.items[key]
+		if len(candidates) == 0 {
+			continue
+		}
+		var nameMatches []libraryItem
+		for _, candidate := range candidates {
+			if strings.EqualFold(candidate.Name, item.Name) {
+				nameMatches = append(nameMatches, candidate)
+			}
+		}
+		if len(nameMatches) == 0 {
+			nameMatches = candidates
+		}
+		return lowestID(nameMatches), true
+	}
+	return libraryItem{}, false
+}
+
+// changedProviderKeys lists the provider IDs of an item whose key is changed by the normalisation
+func changedProviderKeys(providerIDs models.ProviderIDs) []string {
+	var changed []string
+	for provider, id := range providerIDs {
+		raw := rawProviderKey(provider, id)
+		if normalized := models.ProviderKey(provider, id); normalized != raw {
+			changed = append(changed, fmt.Sprintf("%q → %q", raw, normalized))
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// performPreviewNormalization matches the items of a backup by their raw and by their normalised
+// provider IDs and reports the items whose keys or match results differ. Nothing is changed
+func performPreviewNormalization(client *jellyfin.Client, filename string) error {
+	backup, err := loadBackup(filename)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Fetching the library of %s...\n", client.GetConfig().UserName)
+	library, err := client.GetUserItems()
+	if err != nil {
+		return fmt.Errorf("fetching library items: %w", err)
+	}
+	raw := newPreviewIndex(library, rawProviderKey)
+	normalized := newPreviewIndex(library, models.ProviderKey)
+
+	fmt.Printf("\n=== Provider ID Normalisation Preview ===\n")
+	var withChangedKeys, newlyMatched, newlyUnmatched, otherItem int
+	for _, item := range backup.WatchedItems {
+		changed := changedProviderKeys(item.ProviderIDs)
+		before, foundBefore := raw.find(item)
+		after, foundAfter := normalized.find(item)
+
+		name := itemDisplayName(item)
+		switch {
+		case !foundBefore && foundAfter:
+			newlyMatched++
+			fmt.Printf("  + %s - newly matched with \"%s\"\n", name, after.Name)
+		case foundBefore && !foundAfter:
+			newlyUnmatched++
+			fmt.Printf("  ✗ %s - no longer matched, was \"%s\"\n", name, before.Name)
+		case foundBefore && before.ID != after.ID:
+			otherItem++
+			fmt.Printf("  ~ %s - matched with \"%s\" instead of \"%s\"\n", name, after.Name, before.Name)
+		case len(changed) != 0:
+			fmt.Printf("  ○ %s - same result\n", name)
+		default:
+			continue
+		}
+		if len(changed) != 0 {
+			withChangedKeys++
+			fmt.Printf("    %s\n", strings.Join(changed, ", "))
+		}
+	}
+
+	recordCount("changed_keys", withChangedKeys)
+	recordCount("newly_matched", newlyMatched)
+	recordCount("newly_unmatched", newlyUnmatched)
+	recordCount("different_item", otherItem)
+
+	fmt.Printf("\n=== Summary ===\n")
+	fmt.Printf("Items with normalised provider keys: %d\n", withChangedKeys)
+	fmt.Printf("Newly matched: %d\n", newlyMatched)
+	fmt.Printf("No longer matched: %d\n", newlyUnmatched)
+	fmt.Printf("Matched with a different item: %d\n", otherItem)
+	fmt.Printf("Total: %d\n", len(backup.WatchedItems))
+	fmt.Println("Items that are not matched by provider ID are still matched by name on restore, which is the same in both cases")
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// profile is a named Jellyfin server configuration from the config file
+type profile struct {
+	Name       string `json:"name"`
+	ServerURL  string `json:"server"`
+	APIKey     string `json:"api_key"`
+	UserName   string `json:"user"`
+	UserID     string `json:"user_id"`
+	BackupFile string `json:"file"`
+}
+
+// providerConfig is a provider of external IDs, e.g. one added by a plugin, and other
+// spellings of its name that are normalised to it
+type providerConfig struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases"`
+}
+
+// configFile holds all server profiles
+type configFile struct {
+	Profiles []profile `json:"profiles"`
+	// Providers are tried in this order when matching, before all other providers
+	Providers []providerConfig `json:"providers"`
+}
+
+// loadConfig reads the server profiles and providers from a JSON config file
+func loadConfig(filename string) (configFile, error) {
+	var config configFile
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return config, fmt.Errorf("reading config file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("unmarshaling config file: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, p := range config.Profiles {
+		if p.Name == "" {
+			return config, fmt.Errorf("config file contains a profile without name")
+		}
+		if names[p.Name] {
+			return config, fmt.Errorf("config file contains profile %s more than once", p.Name)
+		}
+		names[p.Name] = true
+	}
+	for _, p := range config.Providers {
+		if strings.TrimSpace(p.Name) == "" {
+			return config, fmt.Errorf("config file contains a provider without name")
+		}
+	}
+	return config, nil
+}
+
+// registerProviders makes the providers of the config file known to the matching
+func (c configFile) registerProviders() {
+	if len(c.Providers) == 0 {
+		return
+	}
+	priority := make([]string, len(c.Providers))
+	for i, p := range c.Providers {
+		name := strings.TrimSpace(p.Name)
+		models.RegisterProvider(models.NormalizeProviderName(name), append(p.Aliases, name)...)
+		priority[i] = name
+	}
+	models.SetProviderPriority(priority)
+}
+
+// selectProfiles returns the profile with the given name, or all profiles if all is true
+func selectProfiles(profiles []profile, name string, all bool) ([]profile, error) {
+	if all {
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("config file does not contain any profiles")
+		}
+		return profiles, nil
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return []profile{p}, nil
+		}
+	}
+	return nil, fmt.Errorf("profile not found: %s", name)
+}
+
+// profileBackupFile returns a backup file name that is unique for the profile,
+// e.g. backup.json becomes backup_movies.json
+func profileBackupFile(filename, profileName string) string {
+	dir, base := filepath.Split(filename)
+	extension := ""
+	for _, suffix := range []string{gzipExtension, ".json", ".xml"} {
+		if strings.HasSuffix(strings.ToLower(base), suffix) {
+			extension = base[len(base)-len(suffix):] + extension
+			base = base[:len(base)-len(suffix)]
+		}
+	}
+	return dir + base + "_" + profileName + extension
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// providerIDFormats are the expected formats of the IDs of known providers
+var providerIDFormats = map[string]*regexp.Regexp{
+	models.ProviderImdb:    regexp.MustCompile(`^tt\d+$`),
+	models.ProviderTmdb:    regexp.MustCompile(`^\d+$`),
+	models.ProviderTvdb:    regexp.MustCompile(`^\d+$`),
+	models.ProviderTvMaze:  regexp.MustCompile(`^\d+$`),
+	models.ProviderAniDB:   regexp.MustCompile(`^\d+$`),
+	models.ProviderAniList: regexp.MustCompile(`^\d+$`),
+}
+
+// providerAliases are spellings of known providers used by other tools. They are not
+// normalised, as they are not used by Jellyfin, so IDs stored under them never match
+var providerAliases = map[string]string{
+	"thetvdb":    models.ProviderTvdb,
+	"tvdbid":     models.ProviderTvdb,
+	"themoviedb": models.ProviderTmdb,
+	"tmdbid":     models.ProviderTmdb,
+	"imdbid":     models.ProviderImdb,
+	"tvmazeid":   models.ProviderTvMaze,
+}
+
+// maxMalformedExamples is the number of malformed IDs that are listed individually
+const maxMalformedExamples = 10
+
+// providerNameReport lists the provider IDs of a backup that are spelled inconsistently or malformed
+type providerNameReport struct {
+	// Renamed counts the items per spelling that is normalised, e.g. "tvdb" → "Tvdb"
+	Renamed map[string]int
+	// Aliases counts the items per spelling that looks like a known provider, see providerAliases
+	Aliases map[string]int
+	// Malformed lists the items with an ID that does not have the format of its provider
+	Malformed []string
+	// Trimmed counts the IDs with surrounding spaces, which are removed when matching
+	Trimmed int
+	// Empty counts the providers without an ID, which are ignored
+	Empty int
+}
+
+// checkProviderNames analyses the provider IDs of the items without changing them
+func checkProviderNames(items []models.WatchedItem) providerNameReport {
+	report := providerNameReport{Renamed: make(map[string]int), Aliases: make(map[string]int)}
+	for _, item := range items {
+		for provider, id := range item.ProviderIDs {
+			canonical := models.NormalizeProviderName(provider)
+			if canonical != provider {
+				report.Renamed[provider]++
+			}
+			// Aliases that are registered in the config file are normalised like the others
+			if _, exists := providerAliases[strings.ToLower(strings.TrimSpace(provider))]; exists && canonical == provider {
+				report.Aliases[provider]++
+			}
+			trimmed := strings.TrimSpace(id)
+			if trimmed == "" {
+				report.Empty++
+				continue
+			}
+			if trimmed != id {
+				report.Trimmed++
+			}
+			if format, known := providerIDFormats[canonical]; known && !format.MatchString(trimmed) {
+				report.Malformed = append(report.Malformed, fmt.Sprintf("%s ID \"%s\" of %s", canonical, id, itemDisplayName(item)))
+			}
+		}
+	}
+	return report
+}
+
+// print shows the normalisations that are applied when matching and the IDs that cannot be matched
+func (r providerNameReport) print() {
+	fmt.Printf("\n=== Provider ID Check ===\n")
+	if len(r.Renamed) == 0 && len(r.Aliases) == 0 && len(r.Malformed) == 0 && r.Trimmed == 0 && r.Empty == 0 {
+		fmt.Println("✓ All provider names and IDs are consistent")
+		return
+	}
+	for _, provider := range sortedKeys(r.Renamed) {
+		fmt.Printf("  ~ \"%s\" is matched as \"%s\" (%d items)\n", provider, models.NormalizeProviderName(provider), r.Renamed[provider])
+	}
+	for _, provider := range sortedKeys(r.Aliases) {
+		alias := providerAliases[strings.ToLower(strings.TrimSpace(provider))]
+		fmt.Printf("  ⚠ \"%s\" looks like %s, but is not matched with it (%d items)\n", provider, alias, r.Aliases[provider])
+	}
+	if r.Trimmed > 0 {
+		fmt.Printf("  ~ %d IDs have surrounding spaces, which are removed when matching\n", r.Trimmed)
+	}
+	if r.Empty > 0 {
+		fmt.Printf("  ○ %d providers have an empty ID and are ignored\n", r.Empty)
+	}
+	if len(r.Malformed) > 0 {
+		fmt.Printf("  ✗ %d IDs are malformed and will probably not match:\n", len(r.Malformed))
+		for _, malformed := range r.Malformed[:min(len(r.Malformed), maxMalformedExamples)] {
+			fmt.Printf("    - %s\n", malformed)
+		}
+		if len(r.Malformed) > maxMalformedExamples {
+			fmt.Printf("    ... and %d more\n", len(r.Malformed)-maxMalformedExamples)
+		}
+	}
+}
+
+// sortedKeys returns the keys of a map in alphabetical order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
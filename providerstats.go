@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// providerStats describes how well items of one type can be matched by their provider IDs
+type providerStats struct {
+	Total int `json:"total"`
+	// Providers counts the items that have an ID for each provider
+	Providers map[string]int `json:"providers"`
+	// WithoutProviderIDs counts the items that can only be matched by name
+	WithoutProviderIDs int `json:"without_provider_ids"`
+	// SharedProviderIDs counts the items that share a provider ID with another item
+	SharedProviderIDs int `json:"shared_provider_ids"`
+}
+
+// providerReport is the result of validating the provider IDs of the library
+type providerReport struct {
+	Movies   providerStats `json:"movies"`
+	Episodes providerStats `json:"episodes"`
+}
+
+// collect adds the provider IDs of a group of items, in which a provider ID should be unique
+func (p *providerStats) collect(items []map[string]string) {
+	if p.Providers == nil {
+		p.Providers = make(map[string]int)
+	}
+
+	usage := make(map[string]int)
+	for _, providerIDs := range items {
+		for provider, id := range providerIDs {
+			if id != "" {
+				usage[models.ProviderKey(provider, id)]++
+			}
+		}
+	}
+
+	for _, providerIDs := range items {
+		p.Total++
+		hasID := false
+		shared := false
+		for provider, id := range providerIDs {
+			if id == "" {
+				continue
+			}
+			hasID = true
+			p.Providers[models.NormalizeProviderName(provider)]++
+			if usage[models.ProviderKey(provider, id)] > 1 {
+				shared = true
+			}
+		}
+		if !hasID {
+			p.WithoutProviderIDs++
+		}
+		if shared {
+			p.SharedProviderIDs++
+		}
+	}
+}
+
+// print shows the statistics as a table
+func (p providerStats) print(title string) {
+	fmt.Printf("\n=== %s (%d) ===\n", title, p.Total)
+	providers := make([]string, 0, len(p.Providers))
+	for provider := range p.Providers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		fmt.Printf("  %-22s %6d (%s)\n", provider, p.Providers[provider], percentage(p.Providers[provider], p.Total))
+	}
+	fmt.Printf("  %-22s %6d (%s)\n", "No provider IDs", p.WithoutProviderIDs, percentage(p.WithoutProviderIDs, p.Total))
+	fmt.Printf("  %-22s %6d (%s)\n", "Shared provider IDs", p.SharedProviderIDs, percentage(p.SharedProviderIDs, p.Total))
+}
+
+// percentage formats part as a percentage of total
+func percentage(part, total int) string {
+	if total == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
+
+// performValidateProviderIDs reports how many movies and episodes have which provider IDs,
+// to show how reliably a restore will be able to match them
+func performValidateProviderIDs(client *jellyfin.Client, reportFile string) error {
+	var report providerReport
+
+	fmt.Println("Fetching movies from Jellyfin...")
+	movies, err := client.GetAllMovies()
+	if err != nil {
+		return fmt.Errorf("fetching movies: %w", err)
+	}
+	movieIDs := make([]map[string]string, len(movies))
+	for i, movie := range movies {
+		movieIDs[i] = movie.ProviderIDs
+	}
+	report.Movies.collect(movieIDs)
+
+	fmt.Println("Fetching series from Jellyfin...")
+	series, err := client.GetAllSeries()
+	if err != nil {
+		return fmt.Errorf("fetching series: %w", err)
+	}
+	for i, s := range series {
+		fmt.Printf("\r[%d/%d] Fetching episodes", i+1, len(series))
+		episodes, err := client.GetEpisodesForSeries(s.ID)
+		if err != nil {
+			fmt.Printf("\n  ⚠ Could not fetch episodes of %s: %v\n", s.Name, err)
+			continue
+		}
+		// Episodes are matched within their series, so IDs only need to be unique there
+		episodeIDs := make([]map[string]string, len(episodes))
+		for j, episode := range episodes {
+			episodeIDs[j] = episode.ProviderIDs
+		}
+		report.Episodes.collect(episodeIDs)
+	}
+	fmt.Println()
+	recordCount("movies", len(movies))
+	recordCount("series", len(series))
+
+	report.Movies.print("Movies")
+	report.Episodes.print("Episodes")
+	fmt.Println("\nItems without provider IDs can only be restored by name. Items with shared provider IDs may be matched to the wrong item.")
+
+	if reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling report: %w", err)
+		}
+		if err := os.WriteFile(reportFile, data, 0644); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		fmt.Printf("✓ Wrote report to %s\n", reportFile)
+	}
+	return nil
+}
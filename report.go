@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// missingReport holds the results of finding missing episodes
+type missingReport struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Partial is true if the scan was interrupted before all series were processed
+	Partial       bool `json:"partial"`
+	SeriesChecked int  `json:"series_checked"`
+	SeriesTotal   int  `json:"series_total"`
+	TotalMissing  int  `json:"total_missing"`
+	// Duplicates is the number of missing episodes that were already reported for another series,
+	// e.g. because the series is part of two libraries
+	Duplicates int                  `json:"duplicates,omitempty"`
+	Series     []seriesResult       `json:"series"`
+	Errors     []models.SeriesError `json:"errors"`
+
+	reported map[string]bool
+	// checked are the TVDB IDs of all series that were checked without an error
+	checked map[string]bool
+	// episodeFormat is the template for season and episode numbers in text and Markdown reports
+	episodeFormat string
+}
+
+// add stores the result of a series. Only series with missing episodes are listed and
+// episodes that have already been reported for another series are left out
+func (r *missingReport) add(result seriesResult) {
+	if result.Error != nil {
+		r.Errors = append(r.Errors, *result.Error)
+		return
+	}
+	if r.reported == nil {
+		r.reported = make(map[string]bool)
+		r.checked = make(map[string]bool)
+	}
+	r.checked[result.TvdbID] = true
+	missing := make([]models.MissingEpisode, 0, len(result.Missing))
+	for _, m := range result.Missing {
+		key := missingEpisodeKey(result.TvdbID, m)
+		if r.reported[key] {
+			r.Duplicates++
+			continue
+		}
+		r.reported[key] = true
+		missing = append(missing, m)
+	}
+	if len(missing) == 0 {
+		return
+	}
+	result.Missing = missing
+	r.Series = append(r.Series, result)
+	r.TotalMissing += len(missing)
+}
+
+// sortBySeriesName sorts the series and errors alphabetically, ignoring case, so reports of
+// different runs can be compared regardless of the order the series were processed in
+func (r *missingReport) sortBySeriesName() {
+	sort.SliceStable(r.Series, func(i, j int) bool {
+		return seriesNameLess(r.Series[i].SeriesName, r.Series[i].TvdbID, r.Series[j].SeriesName, r.Series[j].TvdbID)
+	})
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		return seriesNameLess(r.Errors[i].SeriesName, r.Errors[i].TvdbID, r.Errors[j].SeriesName, r.Errors[j].TvdbID)
+	})
+}
+
+// seriesNameLess orders series by name ignoring case, and series with the same name by TVDB ID
+func seriesNameLess(nameA, tvdbIDA, nameB, tvdbIDB string) bool {
+	lowerA, lowerB := strings.ToLower(nameA), strings.ToLower(nameB)
+	if lowerA != lowerB {
+		return lowerA < lowerB
+	}
+	return tvdbIDA < tvdbIDB
+}
+
+// byAirDate returns the missing episodes of all series with their series name, the most
+// recently aired first. Episodes without a valid air date are sorted last
+func (r missingReport) byAirDate() []models.MissingEpisode {
+	var episodes []models.MissingEpisode
+	for _, result := range r.Series {
+		for _, m := range result.Missing {
+			m.SeriesName = result.SeriesName
+			episodes = append(episodes, m)
+		}
+	}
+	sort.SliceStable(episodes, func(i, j int) bool {
+		dateI, errI := time.Parse("2006-01-02", episodes[i].AirDate)
+		dateJ, errJ := time.Parse("2006-01-02", episodes[j].AirDate)
+		if errI != nil || errJ != nil {
+			return errI == nil && errJ != nil
+		}
+		return dateI.After(dateJ)
+	})
+	return episodes
+}
+
+// missingEpisodeKey identifies a missing episode by its TVDB ID, or by the TVDB ID of the
+// series and its season and episode number if the episode ID is unknown
+func missingEpisodeKey(seriesTvdbID string, m models.MissingEpisode) string {
+	if m.TvdbID != 0 {
+		return fmt.Sprintf("episode:%d", m.TvdbID)
+	}
+	return fmt.Sprintf("%s:S%02dE%02d", seriesTvdbID, m.SeasonNumber, m.EpisodeNumber)
+}
+
+// Report formats for -output
+const (
+	reportJSON       = "json"
+	reportMarkdown   = "markdown"
+	reportText       = "text"
+	reportSonarrList = "sonarr-list"
+)
+
+// parseReportFormat returns the report format. If format is empty, it is detected from the
+// file extension: .json for JSON, .md for Markdown and plain text otherwise
+func parseReportFormat(format, filename string) (string, error) {
+	switch strings.ToLower(format) {
+	case reportJSON:
+		return reportJSON, nil
+	case reportMarkdown, "md":
+		return reportMarkdown, nil
+	case reportText, "txt":
+		return reportText, nil
+	case reportSonarrList:
+		return reportSonarrList, nil
+	case "":
+		switch {
+		case strings.HasSuffix(strings.ToLower(filename), ".json"):
+			return reportJSON, nil
+		case strings.HasSuffix(strings.ToLower(filename), ".md"):
+			return reportMarkdown, nil
+		default:
+			return reportText, nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported output format: %s", format)
+	}
+}
+
+// writeReport writes the report to a file in the given format
+func writeReport(filename, format string, report missingReport) error {
+	var data []byte
+	switch format {
+	case reportJSON:
+		var err error
+		data, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling report: %w", err)
+		}
+	case reportMarkdown:
+		data = []byte(report.markdown())
+	case reportSonarrList:
+		data = []byte(report.sonarrList())
+	default:
+		data = []byte(report.text())
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// status returns a line describing how complete the report is
+func (r missingReport) status() string {
+	if r.Partial {
+		return fmt.Sprintf("Partial report: the scan was interrupted, %d of %d series were checked", r.SeriesChecked, r.SeriesTotal)
+	}
+	return fmt.Sprintf("%d of %d series were checked", r.SeriesChecked, r.SeriesTotal)
+}
+
+// text returns the report as plain text
+func (r missingReport) text() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Missing episodes, created %s\n", r.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&builder, "%s\n", r.status())
+	for _, series := range r.Series {
+		fmt.Fprintf(&builder, "\n%s (TVDB: %s)\n", series.SeriesName, series.TvdbID)
+		if series.TvdbURL != "" {
+			fmt.Fprintf(&builder, "  %s\n", series.TvdbURL)
+		}
+		formatter := newEpisodeFormatter(r.episodeFormat, series.Missing)
+		for _, m := range series.Missing {
+			fmt.Fprintf(&builder, "  - %s: %s (Aired: %s)\n", formatter.format(m.SeasonNumber, m.EpisodeNumber), m.EpisodeName, m.AirDate)
+		}
+	}
+	if len(r.Errors) != 0 {
+		fmt.Fprintf(&builder, "\nSeries skipped due to errors:\n")
+		for _, seriesError := range r.Errors {
+			fmt.Fprintf(&builder, "  - %s (TVDB: %s): %s\n", seriesError.SeriesName, seriesError.TvdbID, seriesError.Reason)
+		}
+	}
+	fmt.Fprintf(&builder, "\nTotal missing episodes: %d\n", r.TotalMissing)
+	return builder.String()
+}
+
+// markdown returns the report as Markdown
+func (r missingReport) markdown() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Missing Episodes\n\n")
+	fmt.Fprintf(&builder, "Created %s. %s.\n", r.CreatedAt.Format(time.RFC3339), r.status())
+	for _, series := range r.Series {
+		if series.TvdbURL != "" {
+			fmt.Fprintf(&builder, "\n## [%s](%s)\n\n", series.SeriesName, series.TvdbURL)
+		} else {
+			fmt.Fprintf(&builder, "\n## %s (TVDB: %s)\n\n", series.SeriesName, series.TvdbID)
+		}
+		fmt.Fprintf(&builder, "| Episode | Name | Aired |\n|---|---|---|\n")
+		formatter := newEpisodeFormatter(r.episodeFormat, series.Missing)
+		for _, m := range series.Missing {
+			fmt.Fprintf(&builder, "| %s | %s | %s |\n", escapeMarkdown(formatter.format(m.SeasonNumber, m.EpisodeNumber)), escapeMarkdown(m.EpisodeName), m.AirDate)
+		}
+	}
+	if len(r.Errors) != 0 {
+		fmt.Fprintf(&builder, "\n## Series skipped due to errors\n\n")
+		for _, seriesError := range r.Errors {
+			fmt.Fprintf(&builder, "- %s (TVDB: %s): %s\n", seriesError.SeriesName, seriesError.TvdbID, seriesError.Reason)
+		}
+	}
+	fmt.Fprintf(&builder, "\n**Total missing episodes: %d**\n", r.TotalMissing)
+	return builder.String()
+}
+
+// sonarrList returns one "tvdbId SxxExx" line per missing episode, e.g. for scripting Sonarr.
+// The format is fixed, so the list can be parsed by scripts
+func (r missingReport) sonarrList() string {
+	var builder strings.Builder
+	for _, series := range r.Series {
+		formatter := newEpisodeFormatter(defaultEpisodeFormat, series.Missing)
+		for _, m := range series.Missing {
+			fmt.Fprintf(&builder, "%s %s\n", series.TvdbID, formatter.format(m.SeasonNumber, m.EpisodeNumber))
+		}
+	}
+	return builder.String()
+}
+
+// escapeMarkdown escapes characters that would break a Markdown table cell
+func escapeMarkdown(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// pendingMark is a library item that would be marked as watched by a restore
+type pendingMark struct {
+	Name string
+	ID   string
+	// PlayedDate and PositionTicks are set like for the item of the backup, see writeWatchedState
+	PlayedDate    time.Time
+	PositionTicks int64
+}
+
+// pendingChanges collects the items a restore would mark as watched, so they can be
+// reviewed before anything is changed on the server
+type pendingChanges struct {
+	items []pendingMark
+}
+
+// add records that the library item matched for item would be marked as watched
+func (p *pendingChanges) add(item models.WatchedItem, info libraryItem) {
+	p.items = append(p.items, pendingMark{
+		Name:          itemDisplayName(item),
+		ID:            info.ID,
+		PlayedDate:    item.PlayedDate,
+		PositionTicks: item.PlaybackPositionTicks,
+	})
+}
+
+// print lists all pending changes
+func (p *pendingChanges) print() {
+	fmt.Printf("\n=== Items To Be Marked As Watched ===\n")
+	for _, item := range p.items {
+		if item.PositionTicks > 0 {
+			fmt.Printf("  + %s (resume at %s)\n", item.Name, formatPosition(item.PositionTicks))
+			continue
+		}
+		fmt.Printf("  + %s\n", item.Name)
+	}
+	fmt.Printf("Total: %d\n", len(p.items))
+}
+
+// apply marks all pending items as watched and returns the number of failures.
+// Items that were played in the meantime, e.g. while the changes were reviewed, are skipped
+func (p *pendingChanges) apply(client *jellyfin.Client, chunks *chunker) int {
+	fmt.Printf("\n=== Applying %d Changes ===\n", len(p.items))
+	ids := make([]string, len(p.items))
+	for i, item := range p.items {
+		ids[i] = item.ID
+	}
+	played, err := client.GetPlayedStates(ids)
+	if err != nil {
+		fmt.Printf("⚠ Could not check the current played states, marking all items: %v\n", err)
+	}
+
+	marked := 0
+	for _, item := range p.items {
+		if played[item.ID] {
+			fmt.Printf("  ○ %s - already watched\n", item.Name)
+			marked++
+			continue
+		}
+		chunks.wait(client.Context())
+		if err := writeWatchedState(client, item.ID, item.PlayedDate, item.PositionTicks); err != nil {
+			fmt.Printf("  ✗ %s - failed to mark: %v\n", item.Name, err)
+			if errors.Is(err, jellyfin.ErrWriteDenied) {
+				fmt.Println("✗ Stopped applying the changes, the remaining items were not marked")
+				break
+			}
+			continue
+		}
+		marked++
+	}
+	fmt.Printf("Marked %d of %d items as watched\n", marked, len(p.items))
+	recordCount("applied", marked)
+	recordCount("apply_failed", len(p.items)-marked)
+	return len(p.items) - marked
+}
+
+// itemDisplayName returns the name of a movie, or the series and name of an episode
+func itemDisplayName(item models.WatchedItem) string {
+	if item.Type == models.TypeEpisode {
+		return item.SeriesName + ": " + item.Name
+	}
+	return item.Name
+}
+
+// markAsWatched marks the matched library item as watched, or only records it if the
+// changes of the restore are reviewed first
+func markAsWatched(client *jellyfin.Client, options restoreOptions, item models.WatchedItem, info libraryItem) error {
+	if options.Pending != nil {
+		options.Pending.add(item, info)
+		return nil
+	}
+	options.Chunks.wait(client.Context())
+	return writeWatchedState(client, info.ID, item.PlayedDate, item.PlaybackPositionTicks)
+}
+
+// writeWatchedState marks a library item as watched on the played date of the backup, so
+// Next Up keeps its order. Items in progress get their resume position instead
+func writeWatchedState(client *jellyfin.Client, id string, playedDate time.Time, positionTicks int64) error {
+	if positionTicks > 0 {
+		return client.SetPlaybackPosition(id, positionTicks, playedDate)
+	}
+	return client.MarkAsWatchedAt(id, playedDate)
+}
+
+// formatPosition returns a resume position in ticks of 100 nanoseconds as a duration, e.g. 42m10s
+func formatPosition(ticks int64) string {
+	return (time.Duration(ticks) * 100).Truncate(time.Second).String()
+}
+
+// restoreWithReview lists the items that would be marked as watched and only
+// marks them after the user confirmed it, or if AssumeYes is set.
+// It returns true if all items were restored
+func restoreWithReview(client *jellyfin.Client, items []models.WatchedItem, options restoreOptions) bool {
+	options.Pending = &pendingChanges{}
+	options.Favorites = &favoriteChanges{}
+	complete := restoreItems(client, items, options)
+	options.Pending.print()
+
+	favorites := options.Favorites.count()
+	if len(options.Pending.items) == 0 && favorites == 0 {
+		fmt.Println("Nothing to restore, all matched items are already watched")
+		return complete
+	}
+	question := fmt.Sprintf("Mark %d items as watched?", len(options.Pending.items))
+	if favorites > 0 {
+		question = fmt.Sprintf("Mark %d items as watched and %d items as favorites?", len(options.Pending.items), favorites)
+	}
+	if !options.AssumeYes && !confirm(question) {
+		fmt.Println("Restore cancelled, nothing was changed")
+		return false
+	}
+	failed := 0
+	if len(options.Pending.items) > 0 {
+		failed = options.Pending.apply(client, options.Chunks)
+	}
+	return failed+options.Favorites.apply(client) == 0 && complete
+}
+
+// restoreDryRun matches the items like a restore and lists the items that would be marked
+// as watched, without changing anything on the server
+func restoreDryRun(client *jellyfin.Client, items []models.WatchedItem, options restoreOptions) {
+	options.Pending = &pendingChanges{}
+	options.Favorites = &favoriteChanges{}
+	restoreItems(client, items, options)
+	options.Pending.print()
+	fmt.Println("\nDry run: nothing was marked as watched")
+}
+
+// stdin is shared by all prompts, so answers that were read ahead are kept for the next one
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on the terminal. Anything but yes is treated as no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
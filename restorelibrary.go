@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// matchKind is how a restored item was found in the library
+type matchKind int
+
+const (
+	matchNotFound matchKind = iota
+	// matchByID is a match by provider ID or file path
+	matchByID
+	// matchByName is a low-confidence match by name
+	matchByName
+	// matchSeriesWatched is an episode of a series that -skip-watched-series skipped
+	matchSeriesWatched
+)
+
+// restoreLibrary matches restored items with the library. The movies and the episodes of
+// each series are fetched when the first item needs them and kept for the following items,
+// so the items can be restored in any order
+type restoreLibrary struct {
+	client  *jellyfin.Client
+	options restoreOptions
+	movies  *movieIndex
+	series  map[string]*episodeIndex
+}
+
+// movieIndex holds the movies of the library by provider ID, name and file path
+type movieIndex struct {
+	// err is set if the movies could not be fetched
+	err       error
+	providers providerIndex
+	names     map[string]libraryItem
+	paths     pathIndex
+}
+
+// episodeIndex holds the episodes of a series by provider ID, season and name and file path
+type episodeIndex struct {
+	// err is set if the series or its episodes could not be fetched
+	err error
+	// watched is set if the series is skipped, as all of its episodes are watched
+	watched   bool
+	providers providerIndex
+	// seasonNames has the episodes by season name and name, seasonNumbers by season number and name
+	seasonNames   map[string]libraryItem
+	seasonNumbers map[string]libraryItem
+	paths         pathIndex
+}
+
+func newRestoreLibrary(client *jellyfin.Client, options restoreOptions) *restoreLibrary {
+	return &restoreLibrary{
+		client:  client,
+		options: options,
+		series:  make(map[string]*episodeIndex),
+	}
+}
+
+// match finds the item in the library and returns how it was found. An error is returned
+// if the movies or the series of an episode could not be fetched
+func (l *restoreLibrary) match(item models.WatchedItem) (libraryItem, matchKind, error) {
+	if item.Type == models.TypeEpisode {
+		return l.matchEpisode(item)
+	}
+	return l.matchMovie(item)
+}
+
+func (l *restoreLibrary) matchMovie(movie models.WatchedItem) (libraryItem, matchKind, error) {
+	if l.movies == nil {
+		l.movies = l.loadMovies()
+	}
+	movies := l.movies
+	if movies.err != nil {
+		return libraryItem{}, matchNotFound, movies.err
+	}
+
+	// Try provider IDs first
+	if info, found := movies.providers.find(movie.ProviderIDs, movie.Name); found {
+		return info, matchByID, nil
+	}
+	// Then the file path, if the library uses the same files as the backup
+	if l.options.MatchPaths {
+		if info, found := movies.paths.find(movie.Path); found {
+			return info, matchByID, nil
+		}
+	}
+	// Fallback to name matching
+	if !l.options.NoNameMatch {
+		if info, found := movies.names[movie.Name]; found {
+			return info, matchByName, nil
+		}
+	}
+	return libraryItem{}, matchNotFound, nil
+}
+
+func (l *restoreLibrary) loadMovies() *movieIndex {
+	libraryMovies, err := l.client.GetAllMovies()
+	if err != nil {
+		return &movieIndex{err: fmt.Errorf("fetching movies from server: %w", err)}
+	}
+	movies := &movieIndex{
+		providers: make(providerIndex),
+		names:     make(map[string]libraryItem),
+		paths:     make(pathIndex),
+	}
+	for _, m := range libraryMovies {
+		info := libraryItem{
+			ID:     m.ID,
+			Name:   m.Name,
+			Played: m.Played,
+		}
+		movies.names[m.Name] = info
+		movies.providers.add(m.ProviderIDs, info)
+		movies.paths.add(m.Path, info)
+	}
+	return movies
+}
+
+func (l *restoreLibrary) matchEpisode(episode models.WatchedItem) (libraryItem, matchKind, error) {
+	seriesName := l.options.RenameSeries.canonical(episode.SeriesName)
+	series, loaded := l.series[seriesName]
+	if !loaded {
+		series = l.loadSeries(seriesName)
+		l.series[seriesName] = series
+	}
+	if series.err != nil {
+		return libraryItem{}, matchNotFound, series.err
+	}
+	if series.watched {
+		return libraryItem{}, matchSeriesWatched, nil
+	}
+
+	// Try provider IDs first
+	if info, found := series.providers.find(episode.ProviderIDs, episode.Name); found {
+		return info, matchByID, nil
+	}
+	// Then the file path, if the library uses the same files as the backup
+	if l.options.MatchPaths {
+		if info, found := series.paths.find(episode.Path); found {
+			return info, matchByID, nil
+		}
+	}
+	// Fallback to season + name matching. The season number is preferred,
+	// as season names are localised ("Season 1" vs "Staffel 1")
+	if !l.options.NoNameMatch {
+		if episode.SeasonNumber != nil {
+			if info, found := series.seasonNumbers[fmt.Sprintf("%d:%s", *episode.SeasonNumber, episode.Name)]; found {
+				return info, matchByName, nil
+			}
+		}
+		if info, found := series.seasonNames[episode.SeasonName+":"+episode.Name]; found {
+			return info, matchByName, nil
+		}
+	}
+	return libraryItem{}, matchNotFound, nil
+}
+
+func (l *restoreLibrary) loadSeries(seriesName string) *episodeIndex {
+	series, err := l.client.FindSeries(seriesName, l.options.ExactSeriesOnly)
+	if err != nil {
+		return &episodeIndex{err: fmt.Errorf("finding series %s: %w", seriesName, err)}
+	}
+	// Nothing to do if the user has already watched every episode
+	if l.options.SkipWatchedSeries && series.UnplayedItemCount != nil && *series.UnplayedItemCount == 0 {
+		return &episodeIndex{watched: true}
+	}
+
+	episodes, err := l.client.GetEpisodesForSeries(series.ID)
+	if err != nil {
+		return &episodeIndex{err: fmt.Errorf("fetching episodes of %s: %w", seriesName, err)}
+	}
+	index := &episodeIndex{
+		providers:     make(providerIndex),
+		seasonNames:   make(map[string]libraryItem),
+		seasonNumbers: make(map[string]libraryItem),
+		paths:         make(pathIndex),
+	}
+	for _, ep := range episodes {
+		info := libraryItem{
+			ID:     ep.ID,
+			Name:   ep.Name,
+			Played: ep.Played,
+		}
+		index.seasonNames[ep.SeasonName+":"+ep.Name] = info
+		index.seasonNumbers[fmt.Sprintf("%d:%s", ep.SeasonNumber, ep.Name)] = info
+		index.providers.add(ep.ProviderIDs, info)
+		index.paths.add(ep.Path, info)
+	}
+	return index
+}
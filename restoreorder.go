@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// Orders of a restore for -order
+const (
+	orderOldestFirst = "oldest-first"
+	orderNewestFirst = "newest-first"
+)
+
+// parseRestoreOrder validates the value of -order
+func parseRestoreOrder(value string) (string, error) {
+	switch value {
+	case orderOldestFirst, orderNewestFirst:
+		return value, nil
+	default:
+		return "", fmt.Errorf("unsupported order: %s, expected %s or %s", value, orderOldestFirst, orderNewestFirst)
+	}
+}
+
+// playedBefore returns true if an item played at a is restored before one played at b.
+// Any order other than newest-first restores the oldest items first
+func playedBefore(a, b time.Time, order string) bool {
+	if order == orderNewestFirst {
+		return a.After(b)
+	}
+	return a.Before(b)
+}
+
+// sortByPlayedDate returns a copy of the items in the order they are restored in.
+// Items with the same played date keep their order
+func sortByPlayedDate(items []models.WatchedItem, order string) []models.WatchedItem {
+	sorted := make([]models.WatchedItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return playedBefore(sorted[i].PlayedDate, sorted[j].PlayedDate, order)
+	})
+	return sorted
+}
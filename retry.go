@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// yearSuffix matches a trailing year like " (2010)" in titles
+var yearSuffix = regexp.MustCompile(`\s*\(\d{4}\)\s*$`)
+
+// normalizeTitle simplifies a title for relaxed matching. It removes a trailing year,
+// punctuation and a leading article and converts the title to lower case
+func normalizeTitle(title string) string {
+	title = yearSuffix.ReplaceAllString(title, "")
+	title = strings.ToLower(strings.ReplaceAll(title, "&", " and "))
+
+	var builder strings.Builder
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteRune(' ')
+		}
+	}
+	title = strings.Join(strings.Fields(builder.String()), " ")
+	return strings.TrimPrefix(title, "the ")
+}
+
+// relaxedIndex maps normalised titles to all library items with that title
+type relaxedIndex map[string][]libraryItem
+
+// find returns the item for the title, but only if the match is unambiguous
+func (r relaxedIndex) find(key string) (libraryItem, bool) {
+	candidates := r[key]
+	if len(candidates) != 1 {
+		return libraryItem{}, false
+	}
+	return candidates[0], true
+}
+
+// retryUnmatched tries to find items that could not be matched during restore with relaxed
+// matching and marks them as watched. Each tentative match is logged, so it can be checked.
+// It returns the number of recovered items
+func retryUnmatched(client *jellyfin.Client, unmatched []models.WatchedItem, options restoreOptions) int {
+	var movies []models.WatchedItem
+	episodesBySeries := make(map[string][]models.WatchedItem)
+	for _, item := range unmatched {
+		switch item.Type {
+		case models.TypeMovie:
+			movies = append(movies, item)
+		case models.TypeEpisode:
+			seriesName := options.RenameSeries.canonical(item.SeriesName)
+			episodesBySeries[seriesName] = append(episodesBySeries[seriesName], item)
+		}
+	}
+
+	recovered := 0
+	if len(movies) > 0 {
+		recovered += retryMovies(client, movies, options)
+	}
+	for seriesName, episodes := range episodesBySeries {
+		recovered += retryEpisodes(client, seriesName, episodes, options)
+	}
+	return recovered
+}
+
+// retryMovies matches movies by their normalised name
+func retryMovies(client *jellyfin.Client, movies []models.WatchedItem, options restoreOptions) int {
+	libraryMovies, err := client.GetAllMovies()
+	if err != nil {
+		fmt.Printf("Error fetching movies from server: %v\n", err)
+		return 0
+	}
+
+	index := make(relaxedIndex)
+	for _, m := range libraryMovies {
+		key := normalizeTitle(m.Name)
+		index[key] = append(index[key], libraryItem{ID: m.ID, Name: m.Name, Played: m.Played})
+	}
+
+	recovered := 0
+	for _, movie := range movies {
+		info, found := index.find(normalizeTitle(movie.Name))
+		if !found {
+			fmt.Printf("  ✗ %s - still not found\n", movie.Name)
+			continue
+		}
+		if markRelaxedMatch(client, movie, info, options) {
+			recovered++
+		}
+	}
+	return recovered
+}
+
+// retryEpisodes matches episodes of a series by their normalised name. The season number
+// is used if it is known, otherwise the name has to be unique within the series
+func retryEpisodes(client *jellyfin.Client, seriesName string, episodes []models.WatchedItem, options restoreOptions) int {
+	seriesID, err := client.FindSeriesID(seriesName, options.ExactSeriesOnly)
+	if err != nil {
+		seriesID, err = client.FindSeriesID(yearSuffix.ReplaceAllString(seriesName, ""), options.ExactSeriesOnly)
+	}
+	if err != nil {
+		fmt.Printf("  ✗ %s - series still not found\n", seriesName)
+		return 0
+	}
+
+	libraryEpisodes, err := client.GetEpisodesForSeries(seriesID)
+	if err != nil {
+		fmt.Printf("  ✗ %s - error fetching episodes: %v\n", seriesName, err)
+		return 0
+	}
+
+	bySeason := make(relaxedIndex)
+	byName := make(relaxedIndex)
+	for _, ep := range libraryEpisodes {
+		info := libraryItem{ID: ep.ID, Name: ep.Name, Played: ep.Played}
+		name := normalizeTitle(ep.Name)
+		seasonKey := fmt.Sprintf("%d:%s", ep.SeasonNumber, name)
+		bySeason[seasonKey] = append(bySeason[seasonKey], info)
+		byName[name] = append(byName[name], info)
+	}
+
+	recovered := 0
+	for _, episode := range episodes {
+		name := normalizeTitle(episode.Name)
+		var info libraryItem
+		found := false
+		if episode.SeasonNumber != nil {
+			info, found = bySeason.find(fmt.Sprintf("%d:%s", *episode.SeasonNumber, name))
+		}
+		if !found {
+			info, found = byName.find(name)
+		}
+		if !found {
+			fmt.Printf("  ✗ %s: %s - still not found\n", seriesName, episode.Name)
+			continue
+		}
+		if markRelaxedMatch(client, episode, info, options) {
+			recovered++
+		}
+	}
+	return recovered
+}
+
+// markRelaxedMatch logs a tentative match and marks the library item as watched
+func markRelaxedMatch(client *jellyfin.Client, item models.WatchedItem, info libraryItem, options restoreOptions) bool {
+	fmt.Printf("  ~ %s → %s (relaxed match)\n", itemDisplayName(item), info.Name)
+	if options.OnMatch != nil {
+		options.OnMatch(item, info)
+	}
+
+	if options.MatchOnly || info.Played {
+		return true
+	}
+	if err := markAsWatched(client, options, item, info); err != nil {
+		fmt.Printf("    ✗ Failed to mark as watched: %v\n", err)
+		return false
+	}
+	return true
+}
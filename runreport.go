@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Status values of a run report and its targets
+const (
+	runSucceeded = "success"
+	runFailed    = "failed"
+)
+
+// runReport describes a whole run for schedulers and dashboards. It has the same shape for
+// every command, the results of the command are stored in the counts of each target
+type runReport struct {
+	Command    string      `json:"command"`
+	RunID      string      `json:"run_id"`
+	Version    string      `json:"version"`
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+	Status     string      `json:"status"`
+	Targets    []runTarget `json:"targets"`
+}
+
+// runTarget is the outcome of the command for a single server and user
+type runTarget struct {
+	Profile string         `json:"profile,omitempty"`
+	Server  string         `json:"server"`
+	User    string         `json:"user,omitempty"`
+	Status  string         `json:"status"`
+	Error   string         `json:"error,omitempty"`
+	Counts  map[string]int `json:"counts"`
+}
+
+// currentTarget receives the counts of the running command. It is nil if no -run-report is written
+var currentTarget *runTarget
+
+// recordCount stores a result of the running command for the run report, e.g. the number of
+// restored items. Recording the same name again replaces the value
+func recordCount(name string, value int) {
+	if currentTarget != nil {
+		currentTarget.Counts[name] = value
+	}
+}
+
+// startTarget adds a target to the report and records the counts of the command for it until
+// the next call. It does nothing if report is nil
+func (r *runReport) startTarget(profile, server, user string) {
+	if r == nil {
+		return
+	}
+	r.Targets = append(r.Targets, runTarget{
+		Profile: profile,
+		Server:  server,
+		User:    user,
+		Status:  runSucceeded,
+		Counts:  make(map[string]int),
+	})
+	currentTarget = &r.Targets[len(r.Targets)-1]
+}
+
+// finishTarget sets the status of the current target
+func (r *runReport) finishTarget(err error) {
+	if r == nil || currentTarget == nil {
+		return
+	}
+	if err != nil {
+		currentTarget.Status = runFailed
+		currentTarget.Error = err.Error()
+	}
+	currentTarget = nil
+}
+
+// write sets the status of the run and writes the report to a temporary file first, so
+// schedulers never read a partially written report
+func (r *runReport) write(filename string) error {
+	r.FinishedAt = time.Now()
+	r.Status = runSucceeded
+	for _, target := range r.Targets {
+		if target.Status != runSucceeded {
+			r.Status = runFailed
+		}
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling run report: %w", err)
+	}
+	tempFile := filename + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("writing run report: %w", err)
+	}
+	return os.Rename(tempFile, filename)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// jsonSchemaDialect is the JSON Schema version of -print-schema
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// backupSchema returns a JSON Schema of JSON backup files. It is generated from models.Backup,
+// so fields that are added to the backup are included automatically
+func backupSchema() map[string]any {
+	schema := typeSchema(reflect.TypeFor[models.Backup]())
+	schema["$schema"] = jsonSchemaDialect
+	schema["title"] = "JellyfinManager backup " + appVersion
+	return schema
+}
+
+// typeSchema returns the JSON Schema of a Go type, following the rules of encoding/json
+func typeSchema(t reflect.Type) map[string]any {
+	if t == reflect.TypeFor[time.Time]() {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema returns the JSON Schema of a struct. Fields without omitempty or omitzero are required
+func structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	required := []string{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = typeSchema(field.Type)
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// printBackupSchema prints the JSON Schema of backup files
+func printBackupSchema() error {
+	data, err := json.MarshalIndent(backupSchema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling schema: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
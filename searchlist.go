@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// defaultSearchFormat is used for the lines of -search-list unless -search-format is set
+const defaultSearchFormat = "{series} S{season}E{episode}"
+
+// validateSearchFormat checks that a template for -search-format contains the series name
+func validateSearchFormat(template string) error {
+	if !strings.Contains(template, "{series}") {
+		return fmt.Errorf("search format must contain {series}: %s", template)
+	}
+	return nil
+}
+
+// searchTerm prepares a name for the search of an indexer. Apostrophes are removed, as release
+// names leave them out, "&" is written as "and" and other punctuation is replaced by spaces,
+// e.g. "Grey's Anatomy: Part 1 (2005)" becomes "Greys Anatomy Part 1 2005"
+func searchTerm(name string) string {
+	name = strings.ReplaceAll(name, "&", " and ")
+	var builder strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '\'' || r == '’':
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// searchList returns one search query per missing episode. The template may contain {series},
+// {name}, {season} and {episode}. Numbers always have two digits at least, like in release names
+func (r missingReport) searchList(template string) string {
+	var builder strings.Builder
+	formatter := episodeFormatter{seasonWidth: 2, episodeWidth: 2}
+	for _, series := range r.Series {
+		for _, m := range series.Missing {
+			formatter.template = strings.NewReplacer(
+				"{series}", searchTerm(series.SeriesName),
+				"{name}", searchTerm(m.EpisodeName),
+			).Replace(template)
+			fmt.Fprintln(&builder, strings.Join(strings.Fields(formatter.format(m.SeasonNumber, m.EpisodeNumber)), " "))
+		}
+	}
+	return builder.String()
+}
+
+// writeSearchList writes the search queries for all missing episodes to a file
+func writeSearchList(filename, template string, report missingReport) error {
+	return os.WriteFile(filename, []byte(report.searchList(template)), 0644)
+}
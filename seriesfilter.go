@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+)
+
+// stringList is a flag that can be passed several times
+type stringList []string
+
+// String returns the values separated by commas
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set adds a value
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// regexPrefix marks a series pattern as a regular expression instead of a glob
+const regexPrefix = "re:"
+
+// seriesPattern matches series names with a glob, or with a regular expression if the
+// pattern starts with re:. Both ignore case
+type seriesPattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// parseSeriesPatterns validates the patterns of -exclude
+func parseSeriesPatterns(patterns []string) ([]seriesPattern, error) {
+	result := make([]seriesPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		if expression, isRegex := strings.CutPrefix(pattern, regexPrefix); isRegex {
+			regex, err := regexp.Compile("(?i)" + expression)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", expression, err)
+			}
+			result = append(result, seriesPattern{regex: regex})
+			continue
+		}
+		glob := globName(pattern)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		result = append(result, seriesPattern{glob: glob})
+	}
+	return result, nil
+}
+
+// matches returns true if the pattern matches the name. Globs must match the whole name,
+// regular expressions any part of it
+func (p seriesPattern) matches(name string) bool {
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	matched, _ := path.Match(p.glob, globName(name))
+	return matched
+}
+
+// globName prepares a name or glob for path.Match. Slashes are replaced, as path.Match
+// does not match them with *, but they appear in series names like "Face/Off"
+func globName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "/", "\x00")
+}
+
+// excludeSeries removes the series whose name matches one of the patterns and returns
+// the number of removed series
+func excludeSeries(series []jellyfin.SeriesInfo, patterns []seriesPattern) ([]jellyfin.SeriesInfo, int) {
+	if len(patterns) == 0 {
+		return series, 0
+	}
+	filtered := make([]jellyfin.SeriesInfo, 0, len(series))
+	for _, s := range series {
+		excluded := false
+		for _, pattern := range patterns {
+			if pattern.matches(s.Name) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered, len(series) - len(filtered)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+)
+
+// seriesMapEntry is the setting of a series in the series map
+type seriesMapEntry struct {
+	// Name is the name that is used to search for the series. The name is not changed if empty
+	Name string `json:"name"`
+	// Seasons limits find-missing to these seasons of the series. All seasons are checked if empty
+	Seasons []int `json:"seasons"`
+	// DefaultRuntime replaces -default-runtime for the series in find-missing, if not 0
+	DefaultRuntime int `json:"default_runtime"`
+}
+
+// UnmarshalJSON accepts the new name as a string, or an object with name, seasons and default runtime
+func (e *seriesMapEntry) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		e.Name = name
+		return nil
+	}
+	type entry seriesMapEntry
+	var value entry
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&value); err != nil {
+		return errors.New("expected a name or an object with name, seasons and default_runtime")
+	}
+	*e = seriesMapEntry(value)
+	return nil
+}
+
+// seriesMap maps series names in lower case to the name that is used to search for them
+// and the seasons that are checked for missing episodes
+type seriesMap map[string]seriesMapEntry
+
+// loadSeriesMap reads a JSON object of series names and their entries, e.g.
+// {"Star Trek TNG": "Star Trek: The Next Generation", "Doctor Who": {"seasons": [1, 2]}}.
+// An empty filename returns an empty map
+func loadSeriesMap(filename string) (seriesMap, error) {
+	series := make(seriesMap)
+	if filename == "" {
+		return series, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading series map: %w", err)
+	}
+	var entries map[string]seriesMapEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("unmarshaling series map: %w", err)
+	}
+	for name, entry := range entries {
+		key := strings.ToLower(strings.TrimSpace(name))
+		entry.Name = strings.TrimSpace(entry.Name)
+		if key == "" {
+			return nil, errors.New("series map contains an empty name")
+		}
+		if entry.Name == "" && len(entry.Seasons) == 0 && entry.DefaultRuntime == 0 {
+			return nil, fmt.Errorf("series map entry %s has neither a name, seasons nor a default runtime", name)
+		}
+		if entry.DefaultRuntime < 0 {
+			return nil, fmt.Errorf("series map entry %s has a negative default runtime: %d", name, entry.DefaultRuntime)
+		}
+		for _, season := range entry.Seasons {
+			if season < 0 {
+				return nil, fmt.Errorf("series map entry %s contains an invalid season: %d", name, season)
+			}
+		}
+		if _, exists := series[key]; exists {
+			return nil, fmt.Errorf("series map contains %s more than once", name)
+		}
+		series[key] = entry
+	}
+	return series, nil
+}
+
+// canonical returns the name a series should be searched with. Names that are not
+// in the map are returned unchanged. Case is ignored
+func (m seriesMap) canonical(name string) string {
+	if entry, found := m[strings.ToLower(strings.TrimSpace(name))]; found && entry.Name != "" {
+		return entry.Name
+	}
+	return name
+}
+
+// seasons returns the seasons to check for the series, or nil if all seasons are checked
+func (m seriesMap) seasons(name string) map[int]bool {
+	entry, found := m[strings.ToLower(strings.TrimSpace(name))]
+	if !found || len(entry.Seasons) == 0 {
+		return nil
+	}
+	seasons := make(map[int]bool, len(entry.Seasons))
+	for _, season := range entry.Seasons {
+		seasons[season] = true
+	}
+	return seasons
+}
+
+// defaultRuntime returns the runtime in minutes assumed for episodes of the series without
+// one, or false if the series has no runtime of its own
+func (m seriesMap) defaultRuntime(name string) (int, bool) {
+	entry, found := m[strings.ToLower(strings.TrimSpace(name))]
+	if !found || entry.DefaultRuntime == 0 {
+		return 0, false
+	}
+	return entry.DefaultRuntime, true
+}
+
+// renameSeries replaces the names of the series that are in the map and returns the
+// number of renamed series
+func renameSeries(series []jellyfin.SeriesInfo, names seriesMap) int {
+	renamed := 0
+	for i, s := range series {
+		if name := names.canonical(s.Name); name != s.Name {
+			series[i].Name = name
+			renamed++
+		}
+	}
+	return renamed
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// seasonSpan is the range of episode numbers of a season
+type seasonSpan struct {
+	Season int
+	First  int
+	Last   int
+}
+
+// seasonSpans returns the span of every regular season of the given season and episode
+// numbers, sorted by season. Specials are left out
+func seasonSpans(episodes [][2]int) []seasonSpan {
+	spans := make(map[int]*seasonSpan)
+	for _, ep := range episodes {
+		season, number := ep[0], ep[1]
+		if season == 0 {
+			continue
+		}
+		span, exists := spans[season]
+		if !exists {
+			spans[season] = &seasonSpan{Season: season, First: number, Last: number}
+			continue
+		}
+		span.First = min(span.First, number)
+		span.Last = max(span.Last, number)
+	}
+
+	result := make([]seasonSpan, 0, len(spans))
+	for _, span := range spans {
+		result = append(result, *span)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Season < result[j].Season })
+	return result
+}
+
+// continuedSeasons groups seasons whose episode numbers continue those of the previous season,
+// e.g. a season with episodes 13-24 after one with episodes 1-12
+func continuedSeasons(spans []seasonSpan) [][]seasonSpan {
+	var groups [][]seasonSpan
+	for i, span := range spans {
+		if i > 0 && span.First == spans[i-1].Last+1 {
+			groups[len(groups)-1] = append(groups[len(groups)-1], span)
+			continue
+		}
+		groups = append(groups, []seasonSpan{span})
+	}
+	return groups
+}
+
+// splitSeasonMap maps Jellyfin seasons to the TVDB seasons they are compared with
+type splitSeasonMap map[int][]seasonSpan
+
+// season returns the TVDB season of a Jellyfin episode
+func (m splitSeasonMap) season(season, episode int) int {
+	targets, exists := m[season]
+	if !exists {
+		return season
+	}
+	for _, target := range targets {
+		if episode <= target.Last {
+			return target.Season
+		}
+	}
+	return targets[len(targets)-1].Season
+}
+
+// detectSplitSeasons finds seasons that are split into parts on one side and combined on the
+// other, e.g. "Part 1" and "Part 2" in Jellyfin that are a single season on TVDB. Parts are only
+// recognised if the second part continues the episode numbers of the first. It returns the
+// mapping and a description of every difference that was found
+func detectSplitSeasons(jellyfinSpans, tvdbSpans []seasonSpan) (splitSeasonMap, []string) {
+	tvdbGroups := make(map[int][]seasonSpan)
+	for _, group := range continuedSeasons(tvdbSpans) {
+		tvdbGroups[group[0].Season] = group
+	}
+
+	mapping := make(splitSeasonMap)
+	var notes []string
+	// offset is the number of Jellyfin seasons more than on TVDB so far
+	offset := 0
+	for _, group := range continuedSeasons(jellyfinSpans) {
+		target, exists := tvdbGroups[group[0].Season-offset]
+		if !exists {
+			continue
+		}
+		if len(group) == len(target) && group[0].Season == target[0].Season {
+			continue
+		}
+		// Only combine parts if the other side actually covers their episode numbers,
+		// so a season that merely lacks its first episodes is not merged into the previous one
+		last := group[len(group)-1].Last
+		if len(group) > len(target) && target[len(target)-1].Last < last {
+			continue
+		}
+		if len(group) < len(target) && last < target[len(target)-1].First {
+			continue
+		}
+		for _, span := range group {
+			mapping[span.Season] = target
+		}
+		offset += len(group) - len(target)
+		notes = append(notes, fmt.Sprintf("Compared Jellyfin %s with TVDB %s", describeSeasons(group), describeSeasons(target)))
+	}
+	return mapping, notes
+}
+
+// describeSeasons returns e.g. "season 1" or "seasons 1+2"
+func describeSeasons(spans []seasonSpan) string {
+	numbers := make([]string, len(spans))
+	for i, span := range spans {
+		numbers[i] = strconv.Itoa(span.Season)
+	}
+	if len(numbers) == 1 {
+		return "season " + numbers[0]
+	}
+	return "seasons " + strings.Join(numbers, "+")
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// console is the original stdout. os.Stdout is replaced by a pipe when the output is
+// copied to -log-file, so the terminal is queried through this file
+var console = os.Stdout
+
+// defaultTerminalWidth is used if the width of the terminal cannot be detected
+const defaultTerminalWidth = 80
+
+// terminalWidth returns the number of columns of the terminal. COLUMNS takes precedence,
+// so the width can be set when the output is redirected
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if width := stdoutWidth(); width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// wrapText splits text into lines of at most width characters, breaking at spaces.
+// Words longer than width are put on a line of their own
+func wrapText(text string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		if line.Len() > 0 && len([]rune(line.String()))+1+len([]rune(word)) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// stdoutWidth is not supported on this platform, so the default width is used
+func stdoutWidth() int {
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// stdoutWidth returns the number of columns of the terminal stdout is connected to,
+// or 0 if stdout is not a terminal
+func stdoutWidth() int {
+	var size struct {
+		Rows, Cols, Xpixel, Ypixel uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, console.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size)))
+	if errno != 0 {
+		return 0
+	}
+	return int(size.Cols)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection settings of the shared HTTP transport
+const (
+	// defaultIdleConnections is the number of idle connections kept per server. The default of
+	// net/http is 2, which makes concurrent page requests open and close connections constantly
+	defaultIdleConnections = 10
+	// defaultDialTimeout is how long connecting to a server may take
+	defaultDialTimeout = 10 * time.Second
+	// keepAliveInterval is how often TCP keep-alive probes are sent on idle connections
+	keepAliveInterval = 30 * time.Second
+	// idleConnectionTimeout is how long an unused connection is kept open
+	idleConnectionTimeout = 90 * time.Second
+)
+
+// newTransport returns the transport shared by the Jellyfin and TVDB clients. Connections are
+// reused across the many requests of a run instead of opening a new one for most of them,
+// which is faster and avoids running out of local ports on large libraries
+func newTransport(idleConnections int, dialTimeout time.Duration) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: dialTimeout, KeepAlive: keepAliveInterval}
+	transport.DialContext = dialer.DialContext
+	transport.MaxIdleConns = 0
+	transport.MaxIdleConnsPerHost = idleConnections
+	transport.IdleConnTimeout = idleConnectionTimeout
+	transport.TLSHandshakeTimeout = dialTimeout
+	return transport
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+	"github.com/forceu/jellyfinmanager/models"
+)
+
+// unwatchOptions configures performUnwatch
+type unwatchOptions struct {
+	// Matching is used to find the items of the backup in the library
+	Matching restoreOptions
+	// AssumeYes marks the items as unwatched without asking
+	AssumeYes bool
+	// DryRun only lists the items that would be marked as unwatched
+	DryRun bool
+}
+
+// performUnwatch matches the items of a backup with the library like a restore and removes
+// the watched state of all matched items that are watched on the server
+func performUnwatch(client *jellyfin.Client, filename string, options unwatchOptions) error {
+	backup, err := loadBackup(filename)
+	if err != nil {
+		return err
+	}
+	verifyChecksum(backup)
+
+	var watched []models.WatchedItem
+	matching := options.Matching
+	matching.MatchOnly = true
+	matching.OnMatch = func(item models.WatchedItem, info libraryItem) {
+		if info.Played {
+			item.ID = info.ID
+			watched = append(watched, item)
+		}
+	}
+	fmt.Printf("Matching %d items from backup created at %s for %s\n",
+		len(backup.WatchedItems), backup.CreatedAt.Format(time.RFC3339), client.GetConfig().UserName)
+	restoreItems(client, backup.WatchedItems, matching)
+
+	fmt.Printf("\n=== Items To Be Marked As Unwatched ===\n")
+	for _, item := range watched {
+		fmt.Printf("  - %s\n", itemDisplayName(item))
+	}
+	fmt.Printf("Total: %d\n", len(watched))
+	recordCount("watched_on_server", len(watched))
+	if len(watched) == 0 {
+		fmt.Println("\nNothing to mark as unwatched")
+		return nil
+	}
+	if options.DryRun {
+		fmt.Println("\nDry run: nothing was marked as unwatched")
+		return nil
+	}
+
+	question := fmt.Sprintf("Mark %d items as unwatched for user %s? This cannot be undone",
+		len(watched), client.GetConfig().UserName)
+	if !options.AssumeYes && !confirm(question) {
+		fmt.Println("No items were marked as unwatched")
+		return nil
+	}
+
+	fmt.Printf("\n=== Marking %d Items As Unwatched ===\n", len(watched))
+	unwatched, failed := 0, 0
+	for _, item := range watched {
+		if restoreStopped(client) {
+			break
+		}
+		if err := client.MarkAsUnwatched(item.ID); err != nil {
+			fmt.Printf("  ✗ %s - failed to mark as unwatched: %v\n", itemDisplayName(item), err)
+			failed++
+			continue
+		}
+		unwatched++
+	}
+	skipped := len(watched) - unwatched - failed
+	recordCount("unwatched", unwatched)
+	recordCount("failed", failed)
+	recordCount("skipped", skipped)
+	fmt.Printf("\n=== Unwatch Complete ===\n")
+	fmt.Printf("Marked as unwatched: %d\n", unwatched)
+	fmt.Printf("Failed: %d\n", failed)
+	if skipped > 0 {
+		fmt.Printf("⚠ Stopped early, %d items were not processed\n", skipped)
+	}
+	switch {
+	case deadlineReached(client):
+		return fmt.Errorf("deadline reached before all items were marked as unwatched, %d were not processed", skipped)
+	case client.WriteDenied():
+		return jellyfin.ErrWriteDenied
+	case failed > 0:
+		return fmt.Errorf("%d items could not be marked as unwatched", failed)
+	}
+	return nil
+}
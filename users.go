@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/forceu/jellyfinmanager/api/jellyfin"
+)
+
+// performListUsers prints the name and ID of every user on the server,
+// as plain text or, if format is reportJSON, as JSON
+func performListUsers(client *jellyfin.Client, format string) error {
+	users, err := client.GetUsers()
+	if err != nil {
+		return fmt.Errorf("fetching users: %w", err)
+	}
+	recordCount("users", len(users))
+
+	if format == reportJSON {
+		data, err := json.MarshalIndent(users, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling users: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("\n=== %d Users ===\n", len(users))
+	for _, user := range users {
+		fmt.Printf("  %s (ID: %s)\n", user.Name, user.ID)
+	}
+	return nil
+}